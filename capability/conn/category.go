@@ -0,0 +1,7 @@
+package conn
+
+import (
+	"github.com/mkawserm/abesh/constant"
+)
+
+const Category = string(constant.CategoryNetwork)
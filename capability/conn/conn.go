@@ -0,0 +1,127 @@
+package conn
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mkawserm/abesh/iface"
+	"github.com/mkawserm/abesh/logger"
+	"github.com/mkawserm/abesh/model"
+	"github.com/mkawserm/abesh/registry"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/amjadjibon/nats/constant"
+)
+
+var ErrConnNotFound = errors.New("conn capability not found")
+
+// Conn owns a single nats connection shared by the capabilities which
+// reference its contract id through their `conn` config
+type Conn struct {
+	mCM      model.ConfigMap
+	mMu      sync.Mutex
+	mConn    *nats.Conn
+	mOptions *Options
+}
+
+func (c *Conn) Name() string {
+	return Name
+}
+
+func (c *Conn) Version() string {
+	return constant.NatsVersion
+}
+
+func (c *Conn) Category() string {
+	return Category
+}
+
+func (c *Conn) ContractId() string {
+	return ContractId
+}
+
+func (c *Conn) New() iface.ICapability {
+	return &Conn{}
+}
+
+func (c *Conn) SetConfigMap(cm model.ConfigMap) error {
+	c.mCM = cm
+	c.mOptions = NewOptions(cm, "abesh_nats_conn", true)
+	return nil
+}
+
+func (c *Conn) GetConfigMap() model.ConfigMap {
+	return c.mCM
+}
+
+// Conn returns the shared connection, dialing it on first use
+func (c *Conn) Conn() (*nats.Conn, error) {
+	c.mMu.Lock()
+	defer c.mMu.Unlock()
+
+	if c.mConn != nil {
+		return c.mConn, nil
+	}
+
+	nc, err := c.mOptions.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	logger.L(c.ContractId()).Info("connected",
+		zap.String("nats_url", c.mOptions.NatsUrl),
+		zap.String("client_name", c.mOptions.ClientName))
+
+	c.mConn = nc
+	return c.mConn, nil
+}
+
+// Start dials the connection eagerly, Stop drains it, both only
+// run when the capability is listed under start
+func (c *Conn) Start(ctx context.Context) error {
+	_, err := c.Conn()
+	return err
+}
+
+func (c *Conn) Stop(ctx context.Context) error {
+	c.mMu.Lock()
+	defer c.mMu.Unlock()
+
+	if c.mConn == nil {
+		return nil
+	}
+
+	var err = c.mConn.Drain()
+	c.mConn = nil
+	return err
+}
+
+// Connect returns the shared connection of the conn capability registered
+// under contractId, owned is false since the caller must not close or
+// drain it and only releases its own subscriptions, the conn capability
+// drains it on Stop, an empty contractId dials a private connection from
+// options instead
+func Connect(capabilityRegistry iface.ICapabilityRegistry, contractId string, options *Options) (nc *nats.Conn, owned bool, err error) {
+	if len(contractId) == 0 {
+		nc, err = options.Connect()
+		return nc, true, err
+	}
+
+	if capabilityRegistry == nil {
+		return nil, false, ErrConnNotFound
+	}
+
+	c, ok := capabilityRegistry.Capability(contractId).(*Conn)
+	if !ok {
+		return nil, false, ErrConnNotFound
+	}
+
+	nc, err = c.Conn()
+	return nc, false, err
+}
+
+func init() {
+	registry.GlobalRegistry().AddCapability(&Conn{})
+}
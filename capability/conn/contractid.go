@@ -0,0 +1,3 @@
+package conn
+
+const ContractId = "abesh:nats:conn"
@@ -0,0 +1,3 @@
+package conn
+
+const Name = "abesh_nats_conn"
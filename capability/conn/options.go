@@ -0,0 +1,116 @@
+package conn
+
+import (
+	"time"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats.go"
+)
+
+// Options holds the client connection settings shared by every
+// capability which dials nats
+type Options struct {
+	NatsUrl              string
+	ClientName           string
+	Username             string
+	Password             string
+	Token                string
+	CredentialsFile      string
+	NKeySeedFile         string
+	TLSCert              string
+	TLSKey               string
+	TLSCaCert            string
+	MaxReconnects        int
+	ReconnectWait        time.Duration
+	Timeout              time.Duration
+	ReconnectJitter      time.Duration
+	ReconnectJitterTLS   time.Duration
+	PingInterval         time.Duration
+	MaxPingOut           int
+	ReconnectBufSize     int
+	DrainTimeout         time.Duration
+	RetryOnFailedConnect bool
+}
+
+// NewOptions reads the connection settings from the config map, nats_url
+// accepts a comma separated list of servers, long lived subscribers pass
+// retryOnFailedConnect since the embedded server may be started in the
+// same manifest and not accept connections yet
+func NewOptions(cm model.ConfigMap, clientName string, retryOnFailedConnect bool) *Options {
+	return &Options{
+		NatsUrl:              cm.String("nats_url", "nats://localhost:4222"),
+		ClientName:           cm.String("client_name", clientName),
+		Username:             cm.String("username", ""),
+		Password:             cm.String("password", ""),
+		Token:                cm.String("token", ""),
+		CredentialsFile:      cm.String("credentials_file", ""),
+		NKeySeedFile:         cm.String("nkey_seed_file", ""),
+		TLSCert:              cm.String("tls_cert", ""),
+		TLSKey:               cm.String("tls_key", ""),
+		TLSCaCert:            cm.String("tls_ca_cert", ""),
+		MaxReconnects:        cm.Int("max_reconnects", nats.DefaultMaxReconnect),
+		ReconnectWait:        cm.Duration("reconnect_wait", nats.DefaultReconnectWait),
+		Timeout:              cm.Duration("timeout", nats.DefaultTimeout),
+		ReconnectJitter:      cm.Duration("reconnect_jitter", nats.DefaultReconnectJitter),
+		ReconnectJitterTLS:   cm.Duration("reconnect_jitter_tls", nats.DefaultReconnectJitterTLS),
+		PingInterval:         cm.Duration("ping_interval", nats.DefaultPingInterval),
+		MaxPingOut:           cm.Int("max_ping_out", nats.DefaultMaxPingOut),
+		ReconnectBufSize:     cm.Int("reconnect_buf_size", nats.DefaultReconnectBufSize),
+		DrainTimeout:         cm.Duration("drain_timeout", nats.DefaultDrainTimeout),
+		RetryOnFailedConnect: cm.Bool("retry_on_failed_connect", retryOnFailedConnect),
+	}
+}
+
+func (o *Options) natsOptions() ([]nats.Option, error) {
+	var opts []nats.Option
+	opts = append(opts, nats.Name(o.ClientName))
+	opts = append(opts, nats.MaxReconnects(o.MaxReconnects))
+	opts = append(opts, nats.ReconnectWait(o.ReconnectWait))
+	opts = append(opts, nats.Timeout(o.Timeout))
+	opts = append(opts, nats.ReconnectJitter(o.ReconnectJitter, o.ReconnectJitterTLS))
+	opts = append(opts, nats.PingInterval(o.PingInterval))
+	opts = append(opts, nats.MaxPingsOutstanding(o.MaxPingOut))
+	opts = append(opts, nats.ReconnectBufSize(o.ReconnectBufSize))
+	opts = append(opts, nats.DrainTimeout(o.DrainTimeout))
+	opts = append(opts, nats.RetryOnFailedConnect(o.RetryOnFailedConnect))
+
+	if len(o.Username) != 0 {
+		opts = append(opts, nats.UserInfo(o.Username, o.Password))
+	}
+
+	if len(o.Token) != 0 {
+		opts = append(opts, nats.Token(o.Token))
+	}
+
+	if len(o.CredentialsFile) != 0 {
+		opts = append(opts, nats.UserCredentials(o.CredentialsFile))
+	}
+
+	if len(o.NKeySeedFile) != 0 {
+		opt, err := nats.NkeyOptionFromSeed(o.NKeySeedFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+
+	if len(o.TLSCert) != 0 && len(o.TLSKey) != 0 {
+		opts = append(opts, nats.ClientCert(o.TLSCert, o.TLSKey))
+	}
+
+	if len(o.TLSCaCert) != 0 {
+		opts = append(opts, nats.RootCAs(o.TLSCaCert))
+	}
+
+	return opts, nil
+}
+
+// Connect dials a new connection, the caller owns it
+func (o *Options) Connect() (*nats.Conn, error) {
+	opts, err := o.natsOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	return nats.Connect(o.NatsUrl, opts...)
+}
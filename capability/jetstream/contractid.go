@@ -0,0 +1,3 @@
+package jetstream
+
+const ContractId = "abesh:nats:jetstream"
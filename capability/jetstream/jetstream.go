@@ -0,0 +1,487 @@
+package jetstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkawserm/abesh/iface"
+	"github.com/mkawserm/abesh/logger"
+	"github.com/mkawserm/abesh/model"
+	"github.com/mkawserm/abesh/registry"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/amjadjibon/nats/capability/conn"
+	"github.com/amjadjibon/nats/constant"
+)
+
+var ErrStreamNotDefined = errors.New("stream not defined")
+var ErrDurableNotDefined = errors.New("durable not defined")
+
+type ackType int
+
+const (
+	ack ackType = iota
+	nak
+	term
+)
+
+type consumer struct {
+	stream               string
+	subject              string
+	durable              string
+	batchSize            int
+	authorizer           iface.IAuthorizer
+	authorizerExpression string
+	service              iface.IService
+}
+
+type JetStream struct {
+	mCM                 model.ConfigMap
+	mConn               *nats.Conn
+	mOwnConn            bool
+	mJS                 nats.JetStreamContext
+	mConsumers          []*consumer
+	mEventTransmitter   iface.IEventTransmitter
+	mCapabilityRegistry iface.ICapabilityRegistry
+	mCancel             context.CancelFunc
+	mWG                 sync.WaitGroup
+
+	mStream             string
+	mBatchSize          int
+	mFetchWait          time.Duration
+	mAckWait            time.Duration
+	mMaxDeliver         int
+	mMaxAckPending      int
+	mNakDelay           time.Duration
+	mNakMaxDelay        time.Duration
+	mConnContractId     string
+	mConnOptions        *conn.Options
+	mDefaultContentType string
+	mRequestTimeout     time.Duration
+}
+
+func (j *JetStream) Name() string {
+	return Name
+}
+
+func (j *JetStream) Version() string {
+	return constant.NatsVersion
+}
+
+func (j *JetStream) Category() string {
+	return Category
+}
+
+func (j *JetStream) ContractId() string {
+	return ContractId
+}
+
+func (j *JetStream) New() iface.ICapability {
+	return &JetStream{}
+}
+
+func (j *JetStream) GetConfigMap() model.ConfigMap {
+	return j.mCM
+}
+
+func (j *JetStream) SetConfigMap(cm model.ConfigMap) error {
+	j.mCM = cm
+	j.mConnContractId = cm.String("conn", "")
+	j.mConnOptions = conn.NewOptions(cm, "abesh_nats_jetstream", true)
+	j.mStream = cm.String("stream", "")
+	j.mBatchSize = cm.Int("batch_size", 10)
+	j.mFetchWait = cm.Duration("fetch_wait", 5*time.Second)
+	j.mAckWait = cm.Duration("ack_wait", 30*time.Second)
+	j.mMaxDeliver = cm.Int("max_deliver", -1)
+	j.mMaxAckPending = cm.Int("max_ack_pending", 0)
+	j.mNakDelay = cm.Duration("nak_delay", time.Second)
+	j.mNakMaxDelay = cm.Duration("nak_max_delay", time.Minute)
+	j.mDefaultContentType = cm.String("default_content_type", "application/octet-stream")
+	j.mRequestTimeout = cm.Duration("default_request_timeout", 10*time.Second)
+	return nil
+}
+
+func (j *JetStream) Setup() error {
+	j.mConsumers = make([]*consumer, 0)
+	return nil
+}
+
+func (j *JetStream) SetCapabilityRegistry(capabilityRegistry iface.ICapabilityRegistry) error {
+	j.mCapabilityRegistry = capabilityRegistry
+	return nil
+}
+
+func (j *JetStream) GetCapabilityRegistry() iface.ICapabilityRegistry {
+	return j.mCapabilityRegistry
+}
+
+func (j *JetStream) connect() (*nats.Conn, error) {
+	nc, owned, err := conn.Connect(j.mCapabilityRegistry, j.mConnContractId, j.mConnOptions)
+	if err != nil {
+		return nil, err
+	}
+	j.mOwnConn = owned
+	return nc, nil
+}
+
+func (j *JetStream) Start(ctx context.Context) error {
+	nc, err := j.connect()
+	if err != nil {
+		return err
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		return err
+	}
+
+	j.mConn = nc
+	j.mJS = js
+
+	var nCtx context.Context
+	nCtx, j.mCancel = context.WithCancel(context.Background())
+
+	for _, c := range j.mConsumers {
+		j.mWG.Add(1)
+		go j.run(nCtx, c)
+	}
+
+	return nil
+}
+
+func (j *JetStream) Stop(ctx context.Context) error {
+	if j.mCancel != nil {
+		j.mCancel()
+	}
+
+	j.mWG.Wait()
+
+	if j.mConn == nil || !j.mOwnConn {
+		return nil
+	}
+
+	return j.mConn.Drain()
+}
+
+func (j *JetStream) SetEventTransmitter(eventTransmitter iface.IEventTransmitter) error {
+	j.mEventTransmitter = eventTransmitter
+	return nil
+}
+
+func (j *JetStream) GetEventTransmitter() iface.IEventTransmitter {
+	return j.mEventTransmitter
+}
+
+func (j *JetStream) TransmitInputEvent(contractId string, event *model.Event) error {
+	if j.GetEventTransmitter() != nil {
+		go func() {
+			var err = j.GetEventTransmitter().TransmitInputEvent(contractId, event)
+			if err != nil {
+				logger.L(j.ContractId()).Error(err.Error(),
+					zap.String("version", j.Version()),
+					zap.String("name", j.Name()),
+					zap.String("contract_id", j.ContractId()))
+			}
+		}()
+	}
+	return nil
+}
+
+func (j *JetStream) TransmitOutputEvent(contractId string, event *model.Event) error {
+	if j.GetEventTransmitter() != nil {
+		go func() {
+			err := j.GetEventTransmitter().TransmitOutputEvent(contractId, event)
+			if err != nil {
+				logger.L(j.ContractId()).Error(err.Error(),
+					zap.String("version", j.Version()),
+					zap.String("name", j.Name()),
+					zap.String("contract_id", j.ContractId()))
+			}
+		}()
+	}
+	return nil
+}
+
+// AddService binds the service to a durable pull consumer, trigger values
+// `stream`, `subject`, `durable` and `batch_size` override the capability
+// defaults
+func (j *JetStream) AddService(
+	authorizer iface.IAuthorizer,
+	authorizerExpression string,
+	triggerValues model.ConfigMap,
+	service iface.IService) error {
+
+	logger.L(j.ContractId()).Debug("service add",
+		zap.Any("authorizer", authorizer),
+		zap.Any("expression", authorizerExpression),
+		zap.Any("triggerValues", triggerValues))
+
+	var stream = strings.TrimSpace(triggerValues.String("stream", j.mStream))
+	if len(stream) == 0 {
+		return ErrStreamNotDefined
+	}
+
+	var durable = strings.TrimSpace(triggerValues.String("durable", ""))
+	if len(durable) == 0 {
+		return ErrDurableNotDefined
+	}
+
+	j.mConsumers = append(j.mConsumers, &consumer{
+		stream:               stream,
+		subject:              strings.TrimSpace(triggerValues.String("subject", "")),
+		durable:              durable,
+		batchSize:            triggerValues.Int("batch_size", j.mBatchSize),
+		authorizer:           authorizer,
+		authorizerExpression: authorizerExpression,
+		service:              service,
+	})
+
+	return nil
+}
+
+func (j *JetStream) subscribe(c *consumer) (*nats.Subscription, error) {
+	var opts []nats.SubOpt
+	opts = append(opts, nats.BindStream(c.stream))
+	opts = append(opts, nats.AckExplicit())
+	opts = append(opts, nats.AckWait(j.mAckWait))
+	opts = append(opts, nats.MaxDeliver(j.mMaxDeliver))
+	if j.mMaxAckPending > 0 {
+		opts = append(opts, nats.MaxAckPending(j.mMaxAckPending))
+	}
+	return j.mJS.PullSubscribe(c.subject, c.durable, opts...)
+}
+
+func (j *JetStream) run(ctx context.Context, c *consumer) {
+	defer j.mWG.Done()
+
+	var sub *nats.Subscription
+	var err error
+
+	// the stream or the server may not be available yet,
+	// keep retrying until the consumer is bound
+	for sub == nil {
+		if sub, err = j.subscribe(c); err != nil {
+			logger.L(j.ContractId()).Error(err.Error(),
+				zap.String("stream", c.stream),
+				zap.String("durable", c.durable))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(j.mConnOptions.ReconnectWait):
+			}
+		}
+	}
+
+	if !j.mOwnConn {
+		// release the pull subscription, the shared connection outlives it
+		defer func() {
+			_ = sub.Drain()
+		}()
+	}
+
+	logger.L(j.ContractId()).Info("consumer bound",
+		zap.String("stream", c.stream),
+		zap.String("subject", c.subject),
+		zap.String("durable", c.durable),
+		zap.String("service", c.service.ContractId()))
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		fCtx, cancel := context.WithTimeout(ctx, j.mFetchWait)
+		msgs, err := sub.Fetch(c.batchSize, nats.Context(fCtx))
+		cancel()
+
+		if err != nil {
+			if err == context.DeadlineExceeded || err == context.Canceled || err == nats.ErrTimeout {
+				continue
+			}
+
+			logger.L(j.ContractId()).Error(err.Error(),
+				zap.String("stream", c.stream),
+				zap.String("durable", c.durable))
+
+			if err == nats.ErrConnectionClosed || err == nats.ErrBadSubscription {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(j.mConnOptions.ReconnectWait):
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			j.handle(c, msg)
+		}
+	}
+}
+
+func (j *JetStream) handle(c *consumer, msg *nats.Msg) {
+	timerStart := time.Now()
+
+	defer func() {
+		elapsed := time.Since(timerStart)
+		logger.L(j.ContractId()).Debug("message execution time",
+			zap.String("subject", msg.Subject),
+			zap.Duration("seconds", elapsed))
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.L(j.ContractId()).Error("panic data",
+				zap.String("subject", msg.Subject),
+				zap.String("panic_msg", fmt.Sprintf("%v", r)))
+			j.acknowledge(msg, nak)
+		}
+	}()
+
+	var inputEvent = j.msgToEvent(msg)
+
+	if c.authorizer != nil {
+		if !c.authorizer.IsAuthorized(c.authorizerExpression, inputEvent.Metadata) {
+			j.acknowledge(msg, term)
+			return
+		}
+	}
+
+	_ = j.TransmitInputEvent(c.service.ContractId(), inputEvent)
+
+	nCtx, cancel := context.WithTimeout(context.Background(), j.mRequestTimeout)
+	defer cancel()
+
+	outputEvent, err := c.service.Serve(nCtx, inputEvent)
+	if err != nil {
+		logger.L(j.ContractId()).Error(err.Error(),
+			zap.String("subject", msg.Subject),
+			zap.String("service", c.service.ContractId()))
+		j.acknowledge(msg, errorAckType(err))
+		return
+	}
+
+	if outputEvent != nil {
+		_ = j.TransmitOutputEvent(c.service.ContractId(), outputEvent)
+	}
+
+	j.acknowledge(msg, outputAckType(outputEvent))
+}
+
+// errorAckType terms errors which explicitly report they are not retryable,
+// everything else is retried
+func errorAckType(err error) ackType {
+	if iError := iface.GetError2(err); iError != nil && !iError.IsRetryable() {
+		return term
+	}
+	return nak
+}
+
+// outputAckType maps the output event status code, 408, 429 and 5xx are
+// retried, any other 4xx is permanent
+func outputAckType(event *model.Event) ackType {
+	if event == nil || event.Metadata == nil {
+		return ack
+	}
+
+	var code = event.Metadata.StatusCode
+	switch {
+	case code < 400:
+		return ack
+	case code == 408 || code == 429 || code >= 500:
+		return nak
+	default:
+		return term
+	}
+}
+
+func (j *JetStream) nakDelay(msg *nats.Msg) time.Duration {
+	if j.mNakDelay <= 0 {
+		return 0
+	}
+
+	var delay = j.mNakDelay
+	if meta, err := msg.Metadata(); err == nil {
+		for i := uint64(1); i < meta.NumDelivered && delay < j.mNakMaxDelay; i++ {
+			delay *= 2
+		}
+	}
+
+	if j.mNakMaxDelay > 0 && delay > j.mNakMaxDelay {
+		delay = j.mNakMaxDelay
+	}
+
+	return delay
+}
+
+func (j *JetStream) acknowledge(msg *nats.Msg, t ackType) {
+	var err error
+	switch t {
+	case ack:
+		err = msg.Ack()
+	case nak:
+		if delay := j.nakDelay(msg); delay > 0 {
+			err = msg.NakWithDelay(delay)
+		} else {
+			err = msg.Nak()
+		}
+	case term:
+		err = msg.Term()
+	}
+
+	if err != nil {
+		logger.L(j.ContractId()).Error(err.Error(),
+			zap.String("subject", msg.Subject),
+			zap.String("version", j.Version()),
+			zap.String("name", j.Name()),
+			zap.String("contract_id", j.ContractId()))
+	}
+}
+
+func (j *JetStream) msgToEvent(msg *nats.Msg) *model.Event {
+	var metadata = &model.Metadata{}
+	metadata.Headers = make(map[string]string)
+	metadata.Params = make(map[string]string)
+	metadata.SubscriptionSubject = msg.Subject
+	metadata.ReplySubject = msg.Reply
+	metadata.ContractIdList = append(metadata.ContractIdList, j.ContractId())
+
+	var typeUrl = j.mDefaultContentType
+	for k, v := range msg.Header {
+		if len(v) > 0 {
+			metadata.Headers[k] = v[0]
+			if strings.ToLower(k) == "content-type" {
+				typeUrl = v[0]
+			}
+		}
+	}
+
+	if meta, err := msg.Metadata(); err == nil {
+		metadata.Params["stream"] = meta.Stream
+		metadata.Params["consumer"] = meta.Consumer
+		metadata.Params["stream_sequence"] = strconv.FormatUint(meta.Sequence.Stream, 10)
+		metadata.Params["consumer_sequence"] = strconv.FormatUint(meta.Sequence.Consumer, 10)
+		metadata.Params["num_delivered"] = strconv.FormatUint(meta.NumDelivered, 10)
+		metadata.Params["num_pending"] = strconv.FormatUint(meta.NumPending, 10)
+		metadata.Params["timestamp"] = meta.Timestamp.Format(time.RFC3339Nano)
+	}
+
+	return &model.Event{
+		Metadata: metadata,
+		TypeUrl:  typeUrl,
+		Value:    msg.Data,
+	}
+}
+
+func init() {
+	registry.GlobalRegistry().AddCapability(&JetStream{})
+}
@@ -0,0 +1,3 @@
+package jetstream
+
+const Name = "abesh_nats_jetstream"
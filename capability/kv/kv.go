@@ -2,6 +2,9 @@ package kv
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
 	"time"
 
 	encodingIface "github.com/amjadjibon/encoding/iface"
@@ -11,12 +14,24 @@ import (
 	"github.com/mkawserm/abesh/registry"
 	"github.com/nats-io/nats.go"
 
+	"github.com/amjadjibon/nats/capability/conn"
 	"github.com/amjadjibon/nats/constant"
 )
 
+var ErrKeyExists = errors.New("key exists")
+var ErrWrongLastSequence = errors.New("wrong last sequence")
+
+// defaultAPIPrefix is the jetstream api prefix of nats.go
+const defaultAPIPrefix = "$JS.API."
+
 type KV struct {
 	mCM                   model.ConfigMap
+	mMu                   sync.Mutex
+	mConn                 *nats.Conn
+	mJS                   nats.JetStreamContext
 	mKV                   nats.KeyValue
+	mReaperCancel         context.CancelFunc
+	mReaperWG             sync.WaitGroup
 	mKVBucket             string
 	mKVBucketDescription  string
 	mKVBucketMaxValueSize int32
@@ -26,21 +41,15 @@ type KV struct {
 	mKVBucketStorage      int
 	mKVBucketReplicas     int
 	mKVBucketPlacement    []string
+	mKVKeyTTL             bool
+	mKVKeyTTLStrict       bool
+	mKVKeyTTLReapInterval time.Duration
+	mJetStreamDomain      string
+	mJetStreamAPIPrefix   string
 	mEncoding             encodingIface.IEncoding
 	mEncodingName         string
-	mNatsUrl              string
-	mClientName           string
-	mUsername             string
-	mPassword             string
-	mMaxReconnects        int
-	mReconnectWait        time.Duration
-	mTimeout              time.Duration
-	mReconnectJitter      time.Duration
-	mReconnectJitterTLS   time.Duration
-	mPingInterval         time.Duration
-	mMaxPingOut           int
-	mReconnectBufSize     int
-	mDrainTimeout         time.Duration
+	mConnContractId       string
+	mConnOptions          *conn.Options
 	mCapabilityRegistry   iface.ICapabilityRegistry
 }
 
@@ -74,20 +83,14 @@ func (k *KV) SetConfigMap(cm model.ConfigMap) error {
 	k.mKVBucketMaxBytes = cm.Int64("kv_bucket_max_bytes", 0)
 	k.mKVBucketStorage = cm.Int("kv_bucket_storage", 0)
 	k.mKVBucketReplicas = cm.Int("kv_bucket_replicas", 0)
-	k.mClientName = cm.String("client_name", "abesh_nats_kv")
-	k.mUsername = cm.String("username", "")
-	k.mPassword = cm.String("password", "")
-	k.mNatsUrl = cm.String("nats_url", "nats://localhost:4222")
+	k.mKVKeyTTL = cm.Bool("kv_key_ttl", false)
+	k.mKVKeyTTLStrict = cm.Bool("kv_key_ttl_strict", false)
+	k.mKVKeyTTLReapInterval = cm.Duration("kv_key_ttl_reap_interval", 30*time.Second)
+	k.mJetStreamDomain = cm.String("jetstream_domain", "")
+	k.mJetStreamAPIPrefix = cm.String("jetstream_api_prefix", "")
 	k.mEncodingName = cm.String("encoding", "json")
-	k.mMaxReconnects = cm.Int("max_reconnects", nats.DefaultMaxReconnect)
-	k.mReconnectWait = cm.Duration("reconnect_wait", nats.DefaultReconnectWait)
-	k.mTimeout = cm.Duration("timeout", nats.DefaultTimeout)
-	k.mReconnectJitter = cm.Duration("reconnect_jitter", nats.DefaultReconnectJitter)
-	k.mReconnectJitterTLS = cm.Duration("reconnect_jitter_tls", nats.DefaultReconnectJitterTLS)
-	k.mPingInterval = cm.Duration("ping_interval", nats.DefaultPingInterval)
-	k.mMaxPingOut = cm.Int("max_ping_out", nats.DefaultMaxPingOut)
-	k.mReconnectBufSize = cm.Int("reconnect_buf_size", nats.DefaultReconnectBufSize)
-	k.mDrainTimeout = cm.Duration("drain_timeout", nats.DefaultDrainTimeout)
+	k.mConnContractId = cm.String("conn", "")
+	k.mConnOptions = conn.NewOptions(cm, "abesh_nats_kv", false)
 	return nil
 }
 
@@ -95,29 +98,39 @@ func (k *KV) GetConfigMap() model.ConfigMap {
 	return k.mCM
 }
 
-func (k *KV) getKV() (nats.KeyValue, error) {
-	var opts []nats.Option
-	opts = append(opts, nats.Name(k.mClientName))
-	opts = append(opts, nats.MaxReconnects(k.mMaxReconnects))
-	opts = append(opts, nats.ReconnectWait(k.mReconnectWait))
-	opts = append(opts, nats.Timeout(k.mTimeout))
-	opts = append(opts, nats.ReconnectJitter(k.mReconnectJitter, k.mReconnectJitterTLS))
-	opts = append(opts, nats.PingInterval(k.mPingInterval))
-	opts = append(opts, nats.MaxPingsOutstanding(k.mMaxPingOut))
-	opts = append(opts, nats.ReconnectBufSize(k.mReconnectBufSize))
-	opts = append(opts, nats.DrainTimeout(k.mDrainTimeout))
-	opts = append(opts, nats.UserInfo(k.mUsername, k.mPassword))
-	connect, err := nats.Connect(k.mNatsUrl, opts...)
-	for err != nil {
-		return nil, err
+func (k *KV) SetCapabilityRegistry(capabilityRegistry iface.ICapabilityRegistry) error {
+	k.mCapabilityRegistry = capabilityRegistry
+	return nil
+}
+
+func (k *KV) GetCapabilityRegistry() iface.ICapabilityRegistry {
+	return k.mCapabilityRegistry
+}
+
+// apiPrefix is the jetstream api prefix the bucket handle uses, derived
+// the way nats.go does from jetstream_domain and jetstream_api_prefix
+func (k *KV) apiPrefix() string {
+	var prefix = defaultAPIPrefix
+	if len(k.mJetStreamDomain) != 0 {
+		prefix = "$JS." + k.mJetStreamDomain + ".API."
+	} else if len(k.mJetStreamAPIPrefix) != 0 {
+		prefix = k.mJetStreamAPIPrefix
+	}
+
+	if !strings.HasSuffix(prefix, ".") {
+		prefix += "."
 	}
-	stream, err := connect.JetStream()
+	return prefix
+}
+
+func (k *KV) getKV(connect *nats.Conn) (nats.JetStreamContext, nats.KeyValue, error) {
+	stream, err := connect.JetStream(nats.APIPrefix(k.apiPrefix()))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	kv, err := stream.KeyValue(k.mKVBucket)
 	if err == nil {
-		return kv, nil
+		return stream, kv, nil
 	}
 	if err == nats.ErrBucketNotFound {
 		kv, err = stream.CreateKeyValue(&nats.KeyValueConfig{
@@ -131,24 +144,64 @@ func (k *KV) getKV() (nats.KeyValue, error) {
 			Replicas:     k.mKVBucketReplicas,
 		})
 		if err == nil {
-			return kv, nil
+			return stream, kv, nil
 		}
 	}
-	return nil, err
+	return nil, nil, err
 }
 
 func (k *KV) SetKV() error {
+	k.mMu.Lock()
+	defer k.mMu.Unlock()
+
 	if k.mKV != nil {
 		return nil
 	}
 
-	kv, err := k.getKV()
+	nc, owned, err := conn.Connect(k.mCapabilityRegistry, k.mConnContractId, k.mConnOptions)
 	if err != nil {
 		return err
 	}
 
+	js, kv, err := k.getKV(nc)
+	if err != nil {
+		if owned {
+			nc.Close()
+		}
+		return err
+	}
+
+	if owned {
+		k.mConn = nc
+	}
+	k.mJS = js
 	k.mKV = kv
-	
+
+	return nil
+}
+
+// Stop stops the per key ttl reaper and drains the connection
+// unless it is shared through a conn capability
+func (k *KV) Stop(ctx context.Context) error {
+	k.mMu.Lock()
+	defer k.mMu.Unlock()
+
+	if k.mReaperCancel != nil {
+		k.mReaperCancel()
+		k.mReaperCancel = nil
+	}
+
+	// the reaper reads the bucket handles cleared below
+	k.mReaperWG.Wait()
+
+	if k.mConn != nil {
+		var err = k.mConn.Drain()
+		k.mConn = nil
+		k.mJS = nil
+		k.mKV = nil
+		return err
+	}
+
 	return nil
 }
 
@@ -165,32 +218,148 @@ func (k *KV) Get(ctx context.Context, key string, value interface{}) error {
 		return err
 	}
 
-	get, err := k.mKV.Get(key)
-	if err == nil {
+	entry, err := k.get(key)
+	if err != nil {
 		return err
 	}
 
-	return k.mEncoding.Unmarshal(get.Value(), value)
+	return k.mEncoding.Unmarshal(entry.Data, value)
+}
+
+// GetEntry decodes the latest value of the key into value and returns
+// it along with the revision to be used with Update
+func (k *KV) GetEntry(ctx context.Context, key string, value interface{}) (*Entry, error) {
+	if err := k.SetKV(); err != nil {
+		return nil, err
+	}
+
+	entry, err := k.get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = k.mEncoding.Unmarshal(entry.Data, value); err != nil {
+		return nil, err
+	}
+
+	entry.Value = value
+	return entry, nil
+}
+
+// get returns the latest entry of the key without decoding it, with per
+// key ttl enabled the entry is read from the stream to see its expiry
+func (k *KV) get(key string) (*Entry, error) {
+	if k.mKVKeyTTL {
+		return k.getLast(key)
+	}
+
+	get, err := k.mKV.Get(key)
+	if err != nil {
+		return nil, toError(err)
+	}
+
+	return &Entry{
+		Bucket:    get.Bucket(),
+		Key:       get.Key(),
+		Revision:  get.Revision(),
+		Created:   get.Created(),
+		Operation: get.Operation(),
+		Data:      get.Value(),
+	}, nil
 }
 
+// Set stores the value unconditionally, an existing key is overwritten,
+// use Create to store only if the key is absent
 func (k *KV) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	if err := k.SetKV(); err != nil {
 		return err
 	}
 
+	if err := k.checkTTL(ttl); err != nil {
+		return err
+	}
+
 	var data, err = k.mEncoding.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	_, err = k.mKV.Create(key, data)
-	if err != nil {
+	if ttl <= 0 || !k.mKVKeyTTL {
+		_, err = k.mKV.Put(key, data)
+		return err
+	}
+
+	if _, err = k.putWithTTL(ctx, key, data, ttl); err != nil {
 		return err
 	}
 
+	k.startReaper()
 	return nil
 }
 
+// Put stores the value unconditionally and returns the new revision
+func (k *KV) Put(ctx context.Context, key string, value interface{}) (uint64, error) {
+	if err := k.SetKV(); err != nil {
+		return 0, err
+	}
+
+	var data, err = k.mEncoding.Marshal(value)
+	if err != nil {
+		return 0, err
+	}
+
+	return k.mKV.Put(key, data)
+}
+
+// Create stores the value only if the key does not exist, was deleted or
+// has expired, ErrKeyExists is returned otherwise
+func (k *KV) Create(ctx context.Context, key string, value interface{}) (uint64, error) {
+	if err := k.SetKV(); err != nil {
+		return 0, err
+	}
+
+	var data, err = k.mEncoding.Marshal(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = k.expireKey(key); err != nil {
+		return 0, err
+	}
+
+	revision, err := k.mKV.Create(key, data)
+	if isWrongLastSequence(err) {
+		return 0, ErrKeyExists
+	}
+
+	return revision, err
+}
+
+// Update stores the value only if the latest revision of the key is
+// expectedRevision, ErrWrongLastSequence is returned otherwise, an
+// expired key has no revision to match
+func (k *KV) Update(ctx context.Context, key string, value interface{}, expectedRevision uint64) (uint64, error) {
+	if err := k.SetKV(); err != nil {
+		return 0, err
+	}
+
+	var data, err = k.mEncoding.Marshal(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = k.expireKey(key); err != nil {
+		return 0, err
+	}
+
+	revision, err := k.mKV.Update(key, data, expectedRevision)
+	if isWrongLastSequence(err) {
+		return 0, ErrWrongLastSequence
+	}
+
+	return revision, err
+}
+
 func (k *KV) Delete(ctx context.Context, key string) error {
 	if err := k.SetKV(); err != nil {
 		return err
@@ -199,6 +368,20 @@ func (k *KV) Delete(ctx context.Context, key string) error {
 	return k.mKV.Delete(key)
 }
 
+// toError maps nats key value errors to the abesh ones
+func toError(err error) error {
+	if err == nats.ErrKeyNotFound {
+		return iface.ErrKeyNotfound
+	}
+	return err
+}
+
+// isWrongLastSequence reports the server rejection of a revision
+// mismatch, the server only sends it as a description
+func isWrongLastSequence(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "wrong last sequence")
+}
+
 func init() {
 	registry.GlobalRegistry().AddCapability(&KV{})
 }
@@ -0,0 +1,141 @@
+package kv
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mkawserm/abesh/iface"
+	"github.com/nats-io/nats.go"
+)
+
+// Keys returns the keys starting with prefix, an empty prefix returns all
+// keys, expired keys are left out
+func (k *KV) Keys(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := k.keys(ctx, prefix)
+	if err != nil || !k.mKVKeyTTL {
+		return keys, err
+	}
+
+	var live = make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, err = k.getLast(key); err == iface.ErrKeyNotfound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		live = append(live, key)
+	}
+
+	return live, nil
+}
+
+func (k *KV) keys(ctx context.Context, prefix string) ([]string, error) {
+	if err := k.SetKV(); err != nil {
+		return nil, err
+	}
+
+	keys, err := k.mKV.Keys(nats.Context(ctx))
+	if err == nats.ErrNoKeysFound {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(prefix) == 0 {
+		return keys, nil
+	}
+
+	var filtered = make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			filtered = append(filtered, key)
+		}
+	}
+
+	return filtered, nil
+}
+
+// Scan calls fn with the decoded entry of every key starting with prefix,
+// scanning stops at the first error returned by fn
+func (k *KV) Scan(ctx context.Context, prefix string, fn func(entry *Entry) error) error {
+	// get leaves out the expired keys
+	keys, err := k.keys(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		entry, err := k.get(key)
+		if err == iface.ErrKeyNotfound {
+			// deleted or expired since the keys were listed
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = k.mEncoding.Unmarshal(entry.Data, &entry.Value); err != nil {
+			return err
+		}
+
+		if err = fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// History returns the retained revisions of the key, oldest first, the
+// number of revisions is bound by kv_bucket_history, an expired key ends
+// with its delete marker
+func (k *KV) History(ctx context.Context, key string) ([]*Entry, error) {
+	if err := k.SetKV(); err != nil {
+		return nil, err
+	}
+
+	if err := k.expireKey(key); err != nil {
+		return nil, err
+	}
+
+	history, err := k.mKV.History(key, nats.Context(ctx))
+	if err != nil {
+		return nil, toError(err)
+	}
+
+	var entries = make([]*Entry, 0, len(history))
+	for _, e := range history {
+		entry, err := k.toEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Purge removes every revision of the key, leaving a single purge marker
+func (k *KV) Purge(ctx context.Context, key string) error {
+	if err := k.SetKV(); err != nil {
+		return err
+	}
+
+	return k.mKV.Purge(key)
+}
+
+// PurgeDeletes removes the data of deleted and purged keys along with
+// their markers
+func (k *KV) PurgeDeletes(ctx context.Context) error {
+	if err := k.SetKV(); err != nil {
+		return err
+	}
+
+	return k.mKV.PurgeDeletes(nats.Context(ctx))
+}
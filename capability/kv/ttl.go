@@ -0,0 +1,264 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkawserm/abesh/iface"
+	"github.com/mkawserm/abesh/logger"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// ExpiresAtHeader carries the unix nano expiry of a value stored with a ttl
+const ExpiresAtHeader = "Abesh-Kv-Expires-At"
+
+// the operation header and values nats.KeyValue sets on delete and purge
+const (
+	kvOperationHeader = "KV-Operation"
+	kvOperationDelete = "DEL"
+	kvOperationPurge  = "PURGE"
+)
+
+var ErrTTLNotSupported = errors.New("ttl can not be honored")
+
+// validKeyRe is the key pattern of nats.KeyValue
+var validKeyRe = regexp.MustCompile(`\A[-/_=\.a-zA-Z0-9]+\z`)
+
+// checkTTL reports whether the ttl can be honored, per key ttl must be
+// enabled and a bucket ttl would expire the key earlier than asked, with
+// kv_key_ttl_strict disabled the ttl is applied on a best effort basis
+func (k *KV) checkTTL(ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	if k.mKVKeyTTL && (k.mKVBucketTTL == 0 || ttl <= k.mKVBucketTTL) {
+		return nil
+	}
+
+	if k.mKVKeyTTLStrict {
+		return ErrTTLNotSupported
+	}
+
+	logger.L(k.ContractId()).Warn("ttl can not be honored",
+		zap.Duration("ttl", ttl),
+		zap.Duration("bucket_ttl", k.mKVBucketTTL),
+		zap.Bool("key_ttl", k.mKVKeyTTL))
+
+	return nil
+}
+
+func (k *KV) streamName() string {
+	return "KV_" + k.mKVBucket
+}
+
+func (k *KV) subject(key string) string {
+	return "$KV." + k.mKVBucket + "." + key
+}
+
+// putSubject is the subject nats.KeyValue puts the key to, a non default
+// api prefix routes the put to the domain of the bucket
+func (k *KV) putSubject(key string) string {
+	var prefix = k.apiPrefix()
+	if prefix == defaultAPIPrefix {
+		return k.subject(key)
+	}
+	return prefix + k.subject(key)
+}
+
+// putWithTTL publishes the value straight to the bucket stream since
+// nats.KeyValue does not allow headers on put
+func (k *KV) putWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) (uint64, error) {
+	if !validKey(key) {
+		return 0, nats.ErrInvalidKey
+	}
+
+	var msg = nats.NewMsg(k.putSubject(key))
+	msg.Data = data
+	msg.Header.Set(ExpiresAtHeader, strconv.FormatInt(time.Now().Add(ttl).UnixNano(), 10))
+
+	ack, err := k.mJS.PublishMsg(msg, nats.Context(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	return ack.Sequence, nil
+}
+
+func expired(header nats.Header) bool {
+	var expiresAt = header.Get(ExpiresAtHeader)
+	if len(expiresAt) == 0 {
+		return false
+	}
+
+	nano, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return time.Now().UnixNano() >= nano
+}
+
+// getLast reads the latest entry of the key along with its stream message
+// headers in a single request, deleted, purged and expired keys are
+// reported as iface.ErrKeyNotfound, expired keys are deleted on the way
+func (k *KV) getLast(key string) (*Entry, error) {
+	if !validKey(key) {
+		return nil, nats.ErrInvalidKey
+	}
+
+	msg, err := k.lastMsg(key)
+	if err != nil {
+		if err == nats.ErrMsgNotFound {
+			return nil, iface.ErrKeyNotfound
+		}
+		return nil, err
+	}
+
+	switch msg.Header.Get(kvOperationHeader) {
+	case kvOperationDelete, kvOperationPurge:
+		return nil, iface.ErrKeyNotfound
+	}
+
+	if expired(msg.Header) {
+		k.expire(key, msg.Sequence)
+		return nil, iface.ErrKeyNotfound
+	}
+
+	return &Entry{
+		Bucket:    k.mKVBucket,
+		Key:       key,
+		Revision:  msg.Sequence,
+		Created:   msg.Time,
+		Operation: nats.KeyValuePut,
+		Data:      msg.Data,
+	}, nil
+}
+
+// lastMsgGetter is implemented by the jetstream context of nats.go but
+// not part of nats.JetStreamContext in this version
+type lastMsgGetter interface {
+	GetLastMsg(name, subject string, opts ...nats.JSOpt) (*nats.RawStreamMsg, error)
+}
+
+// lastMsg reads the latest message of the key, falling back to the key
+// value get followed by a lookup of its revision
+func (k *KV) lastMsg(key string) (*nats.RawStreamMsg, error) {
+	if js, ok := k.mJS.(lastMsgGetter); ok {
+		return js.GetLastMsg(k.streamName(), k.subject(key))
+	}
+
+	get, err := k.mKV.Get(key)
+	if err == nats.ErrKeyNotFound {
+		return nil, nats.ErrMsgNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return k.mJS.GetMsg(k.streamName(), get.Revision())
+}
+
+// validKey rejects the keys nats.KeyValue rejects, a wildcard would
+// otherwise match the last message of another key
+func validKey(key string) bool {
+	return len(key) != 0 &&
+		!strings.HasPrefix(key, ".") &&
+		!strings.HasSuffix(key, ".") &&
+		validKeyRe.MatchString(key)
+}
+
+// startReaper starts the reaper on the first write with a ttl, buckets
+// without such keys are never scanned
+func (k *KV) startReaper() {
+	k.mMu.Lock()
+	defer k.mMu.Unlock()
+
+	if k.mReaperCancel != nil || k.mKV == nil || k.mKVKeyTTLReapInterval <= 0 {
+		return
+	}
+
+	var ctx context.Context
+	ctx, k.mReaperCancel = context.WithCancel(context.Background())
+	k.mReaperWG.Add(1)
+	go k.reap(ctx)
+}
+
+// expireKey deletes the key when its ttl has passed so that conditional
+// writes see it as absent
+func (k *KV) expireKey(key string) error {
+	if !k.mKVKeyTTL {
+		return nil
+	}
+
+	if _, err := k.getLast(key); err != nil && err != iface.ErrKeyNotfound {
+		return err
+	}
+
+	return nil
+}
+
+// isExpired reports whether the put of the watch entry has expired, the
+// key is deleted on the way when it is still the latest revision
+func (k *KV) isExpired(e nats.KeyValueEntry) bool {
+	if !k.mKVKeyTTL || e.Operation() != nats.KeyValuePut {
+		return false
+	}
+
+	msg, err := k.mJS.GetMsg(k.streamName(), e.Revision())
+	if err != nil || !expired(msg.Header) {
+		return false
+	}
+
+	k.expire(e.Key(), e.Revision())
+	return true
+}
+
+// expire deletes the key only if it was not written since revision
+func (k *KV) expire(key string, revision uint64) {
+	err := k.mKV.Delete(key, nats.LastRevision(revision))
+	if err != nil && !isWrongLastSequence(err) {
+		logger.L(k.ContractId()).Error(err.Error(),
+			zap.String("key", key),
+			zap.Uint64("revision", revision))
+	}
+}
+
+func (k *KV) reap(ctx context.Context) {
+	defer k.mReaperWG.Done()
+
+	var ticker = time.NewTicker(k.mKVKeyTTLReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		keys, err := k.mKV.Keys()
+		if err != nil {
+			if err != nats.ErrNoKeysFound {
+				logger.L(k.ContractId()).Error(err.Error())
+			}
+			continue
+		}
+
+		for _, key := range keys {
+			if ctx.Err() != nil {
+				return
+			}
+
+			// getLast deletes the key when it has expired
+			if _, err = k.getLast(key); err != nil && err != iface.ErrKeyNotfound {
+				logger.L(k.ContractId()).Error(err.Error(), zap.String("key", key))
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package kv
+
+import (
+	"context"
+	"time"
+
+	"github.com/mkawserm/abesh/logger"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// Entry is a key value entry with its value decoded through the
+// configured encoding, Data holds the raw bytes, Value is nil when
+// Data can not be decoded
+type Entry struct {
+	Bucket    string
+	Key       string
+	Revision  uint64
+	Created   time.Time
+	Operation nats.KeyValueOp
+	Data      []byte
+	Value     interface{}
+}
+
+// toEntry returns the entry with the raw Data even when decoding fails
+func (k *KV) toEntry(e nats.KeyValueEntry) (*Entry, error) {
+	var entry = &Entry{
+		Bucket:    e.Bucket(),
+		Key:       e.Key(),
+		Revision:  e.Revision(),
+		Created:   e.Created(),
+		Operation: e.Operation(),
+		Data:      e.Value(),
+	}
+
+	if entry.Operation == nats.KeyValuePut && len(entry.Data) != 0 {
+		if err := k.mEncoding.Unmarshal(entry.Data, &entry.Value); err != nil {
+			entry.Value = nil
+			return entry, err
+		}
+	}
+
+	return entry, nil
+}
+
+// Watch streams every change of the keys matching keyPattern, the latest
+// value of each matching key is sent first, expired values are skipped,
+// values the encoding can not decode are sent with a nil Value,
+// the channel is closed once ctx is done
+func (k *KV) Watch(ctx context.Context, keyPattern string, opts ...nats.WatchOpt) (<-chan *Entry, error) {
+	if err := k.SetKV(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := k.mKV.Watch(keyPattern, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var ch = make(chan *Entry)
+
+	go func() {
+		defer close(ch)
+		defer func() {
+			_ = watcher.Stop()
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-watcher.Updates():
+				if !ok {
+					return
+				}
+
+				// nil marks the end of the initial values
+				if e == nil {
+					continue
+				}
+
+				if k.isExpired(e) {
+					continue
+				}
+
+				// values written with another encoding are still sent,
+				// with the raw Data only
+				entry, err := k.toEntry(e)
+				if err != nil {
+					logger.L(k.ContractId()).Warn(err.Error(),
+						zap.String("key", e.Key()),
+						zap.Uint64("revision", e.Revision()))
+				}
+
+				select {
+				case ch <- entry:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,7 @@
+package kvwatch
+
+import (
+	"github.com/mkawserm/abesh/constant"
+)
+
+const Category = string(constant.CategoryTrigger)
@@ -0,0 +1,3 @@
+package kvwatch
+
+const ContractId = "abesh:nats:kvwatch"
@@ -0,0 +1,296 @@
+package kvwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkawserm/abesh/iface"
+	"github.com/mkawserm/abesh/logger"
+	"github.com/mkawserm/abesh/model"
+	"github.com/mkawserm/abesh/registry"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/amjadjibon/nats/capability/kv"
+	"github.com/amjadjibon/nats/constant"
+)
+
+var ErrKVNotFound = errors.New("kv capability not found")
+
+type watcher struct {
+	key                  string
+	includeHistory       bool
+	ignoreDeletes        bool
+	authorizer           iface.IAuthorizer
+	authorizerExpression string
+	service              iface.IService
+}
+
+// KVWatch fires an event to the bound service for every change
+// of the watched keys of a KV capability
+type KVWatch struct {
+	mCM                 model.ConfigMap
+	mKV                 *kv.KV
+	mWatchers           []*watcher
+	mCancel             context.CancelFunc
+	mWG                 sync.WaitGroup
+	mEventTransmitter   iface.IEventTransmitter
+	mCapabilityRegistry iface.ICapabilityRegistry
+
+	mKVContractId       string
+	mDefaultContentType string
+	mRequestTimeout     time.Duration
+	mRetryWait          time.Duration
+}
+
+func (w *KVWatch) Name() string {
+	return Name
+}
+
+func (w *KVWatch) Version() string {
+	return constant.NatsVersion
+}
+
+func (w *KVWatch) Category() string {
+	return Category
+}
+
+func (w *KVWatch) ContractId() string {
+	return ContractId
+}
+
+func (w *KVWatch) New() iface.ICapability {
+	return &KVWatch{}
+}
+
+func (w *KVWatch) GetConfigMap() model.ConfigMap {
+	return w.mCM
+}
+
+func (w *KVWatch) SetConfigMap(cm model.ConfigMap) error {
+	w.mCM = cm
+	w.mKVContractId = cm.String("kv", kv.ContractId)
+	w.mDefaultContentType = cm.String("default_content_type", "application/octet-stream")
+	w.mRequestTimeout = cm.Duration("default_request_timeout", time.Second)
+	w.mRetryWait = cm.Duration("reconnect_wait", nats.DefaultReconnectWait)
+	return nil
+}
+
+func (w *KVWatch) SetCapabilityRegistry(capabilityRegistry iface.ICapabilityRegistry) error {
+	w.mCapabilityRegistry = capabilityRegistry
+	return nil
+}
+
+func (w *KVWatch) GetCapabilityRegistry() iface.ICapabilityRegistry {
+	return w.mCapabilityRegistry
+}
+
+func (w *KVWatch) Setup() error {
+	w.mWatchers = make([]*watcher, 0)
+	return nil
+}
+
+func (w *KVWatch) Start(ctx context.Context) error {
+	if w.mCapabilityRegistry == nil {
+		return ErrKVNotFound
+	}
+
+	k, ok := w.mCapabilityRegistry.Capability(w.mKVContractId).(*kv.KV)
+	if !ok {
+		return ErrKVNotFound
+	}
+	w.mKV = k
+
+	var nCtx context.Context
+	nCtx, w.mCancel = context.WithCancel(context.Background())
+
+	for _, wt := range w.mWatchers {
+		w.mWG.Add(1)
+		go w.run(nCtx, wt)
+	}
+
+	return nil
+}
+
+func (w *KVWatch) Stop(ctx context.Context) error {
+	if w.mCancel != nil {
+		w.mCancel()
+	}
+
+	w.mWG.Wait()
+	return nil
+}
+
+func (w *KVWatch) SetEventTransmitter(eventTransmitter iface.IEventTransmitter) error {
+	w.mEventTransmitter = eventTransmitter
+	return nil
+}
+
+func (w *KVWatch) GetEventTransmitter() iface.IEventTransmitter {
+	return w.mEventTransmitter
+}
+
+func (w *KVWatch) TransmitInputEvent(contractId string, event *model.Event) error {
+	if w.GetEventTransmitter() != nil {
+		go func() {
+			var err = w.GetEventTransmitter().TransmitInputEvent(contractId, event)
+			if err != nil {
+				logger.L(w.ContractId()).Error(err.Error(),
+					zap.String("version", w.Version()),
+					zap.String("name", w.Name()),
+					zap.String("contract_id", w.ContractId()))
+			}
+		}()
+	}
+	return nil
+}
+
+func (w *KVWatch) TransmitOutputEvent(contractId string, event *model.Event) error {
+	if w.GetEventTransmitter() != nil {
+		go func() {
+			err := w.GetEventTransmitter().TransmitOutputEvent(contractId, event)
+			if err != nil {
+				logger.L(w.ContractId()).Error(err.Error(),
+					zap.String("version", w.Version()),
+					zap.String("name", w.Name()),
+					zap.String("contract_id", w.ContractId()))
+			}
+		}()
+	}
+	return nil
+}
+
+// AddService binds the service to the key pattern given by the trigger
+// value `key`, all keys are watched when absent
+func (w *KVWatch) AddService(
+	authorizer iface.IAuthorizer,
+	authorizerExpression string,
+	triggerValues model.ConfigMap,
+	service iface.IService) error {
+
+	logger.L(w.ContractId()).Debug("service add",
+		zap.Any("authorizer", authorizer),
+		zap.Any("expression", authorizerExpression),
+		zap.Any("triggerValues", triggerValues))
+
+	w.mWatchers = append(w.mWatchers, &watcher{
+		key:                  strings.TrimSpace(triggerValues.String("key", ">")),
+		includeHistory:       triggerValues.Bool("include_history", false),
+		ignoreDeletes:        triggerValues.Bool("ignore_deletes", false),
+		authorizer:           authorizer,
+		authorizerExpression: authorizerExpression,
+		service:              service,
+	})
+
+	return nil
+}
+
+// watch binds the watcher, the server or the bucket may not be
+// available yet, so it keeps retrying until ctx is done
+func (w *KVWatch) watch(ctx context.Context, wt *watcher) (<-chan *kv.Entry, error) {
+	var opts []nats.WatchOpt
+	if wt.includeHistory {
+		opts = append(opts, nats.IncludeHistory())
+	}
+	if wt.ignoreDeletes {
+		opts = append(opts, nats.IgnoreDeletes())
+	}
+
+	for {
+		ch, err := w.mKV.Watch(ctx, wt.key, opts...)
+		if err == nil {
+			return ch, nil
+		}
+
+		logger.L(w.ContractId()).Error(err.Error(),
+			zap.String("key", wt.key),
+			zap.String("service", wt.service.ContractId()))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(w.mRetryWait):
+		}
+	}
+}
+
+func (w *KVWatch) run(ctx context.Context, wt *watcher) {
+	defer w.mWG.Done()
+
+	ch, err := w.watch(ctx, wt)
+	if err != nil {
+		return
+	}
+
+	logger.L(w.ContractId()).Info("watching",
+		zap.String("key", wt.key),
+		zap.String("service", wt.service.ContractId()))
+
+	for entry := range ch {
+		w.handle(wt, entry)
+	}
+}
+
+func (w *KVWatch) handle(wt *watcher, entry *kv.Entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.L(w.ContractId()).Error("panic data",
+				zap.String("key", entry.Key),
+				zap.String("panic_msg", fmt.Sprintf("%v", r)))
+		}
+	}()
+
+	var inputEvent = w.entryToEvent(entry)
+
+	if wt.authorizer != nil {
+		if !wt.authorizer.IsAuthorized(wt.authorizerExpression, inputEvent.Metadata) {
+			return
+		}
+	}
+
+	_ = w.TransmitInputEvent(wt.service.ContractId(), inputEvent)
+
+	nCtx, cancel := context.WithTimeout(context.Background(), w.mRequestTimeout)
+	defer cancel()
+
+	outputEvent, err := wt.service.Serve(nCtx, inputEvent)
+	if err != nil {
+		logger.L(w.ContractId()).Error(err.Error(),
+			zap.String("key", entry.Key),
+			zap.String("service", wt.service.ContractId()))
+		return
+	}
+
+	if outputEvent != nil {
+		_ = w.TransmitOutputEvent(wt.service.ContractId(), outputEvent)
+	}
+}
+
+func (w *KVWatch) entryToEvent(entry *kv.Entry) *model.Event {
+	var metadata = &model.Metadata{}
+	metadata.Method = entry.Operation.String()
+	metadata.Path = entry.Key
+	metadata.Headers = make(map[string]string)
+	metadata.Params = make(map[string]string)
+	metadata.Params["bucket"] = entry.Bucket
+	metadata.Params["key"] = entry.Key
+	metadata.Params["revision"] = strconv.FormatUint(entry.Revision, 10)
+	metadata.Params["operation"] = entry.Operation.String()
+	metadata.Params["created"] = entry.Created.Format(time.RFC3339Nano)
+	metadata.ContractIdList = append(metadata.ContractIdList, w.ContractId())
+
+	return &model.Event{
+		Metadata: metadata,
+		TypeUrl:  w.mDefaultContentType,
+		Value:    entry.Data,
+	}
+}
+
+func init() {
+	registry.GlobalRegistry().AddCapability(&KVWatch{})
+}
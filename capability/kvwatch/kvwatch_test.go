@@ -0,0 +1,135 @@
+package kvwatch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	_ "github.com/amjadjibon/encoding"
+	"github.com/mkawserm/abesh/iface"
+	"github.com/mkawserm/abesh/model"
+
+	"github.com/amjadjibon/nats/capability/kv"
+	natsServer "github.com/amjadjibon/nats/capability/nats"
+)
+
+type capabilityRegistry map[string]iface.ICapability
+
+func (r capabilityRegistry) Capability(contractId string) iface.ICapability {
+	return r[contractId]
+}
+
+type service struct {
+	mEvents chan *model.Event
+}
+
+func (s *service) Name() string           { return "test" }
+func (s *service) Version() string        { return "0.0.0" }
+func (s *service) Category() string       { return "service" }
+func (s *service) ContractId() string     { return "abesh:test" }
+func (s *service) New() iface.ICapability { return &service{} }
+func (s *service) Serve(_ context.Context, event *model.Event) (*model.Event, error) {
+	select {
+	case s.mEvents <- event:
+	default:
+	}
+	return nil, nil
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestWatchStartsBeforeServer(t *testing.T) {
+	var port = freePort(t)
+	var url = fmt.Sprintf("nats://127.0.0.1:%d", port)
+
+	var k = &kv.KV{}
+	if err := k.SetConfigMap(model.ConfigMap{"nats_url": url, "kv_bucket": "watch"}); err != nil {
+		t.Fatalf("kv set config map: %v", err)
+	}
+	if err := k.Setup(); err != nil {
+		t.Fatalf("kv setup: %v", err)
+	}
+	defer func() {
+		_ = k.Stop(context.Background())
+	}()
+
+	var w = &KVWatch{}
+	if err := w.SetConfigMap(model.ConfigMap{"reconnect_wait": "50ms"}); err != nil {
+		t.Fatalf("set config map: %v", err)
+	}
+	if err := w.SetCapabilityRegistry(capabilityRegistry{kv.ContractId: k}); err != nil {
+		t.Fatalf("set capability registry: %v", err)
+	}
+	if err := w.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	var svc = &service{mEvents: make(chan *model.Event, 1)}
+	if err := w.AddService(nil, "", model.ConfigMap{"key": "greeting"}, svc); err != nil {
+		t.Fatalf("add service: %v", err)
+	}
+
+	// the watcher starts while nothing listens on the url yet
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer func() {
+		_ = w.Stop(context.Background())
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	var n = &natsServer.Nats{}
+	if err := n.SetConfigMap(model.ConfigMap{
+		"host":      "127.0.0.1",
+		"port":      fmt.Sprint(port),
+		"jetstream": "true",
+		"store_dir": t.TempDir(),
+	}); err != nil {
+		t.Fatalf("server set config map: %v", err)
+	}
+	if err := n.Setup(); err != nil {
+		t.Fatalf("server setup: %v", err)
+	}
+	if err := n.Start(context.Background()); err != nil {
+		t.Fatalf("server start: %v", err)
+	}
+	// the server outlives the watcher and the kv connection
+	t.Cleanup(func() {
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = n.Stop(ctx)
+	})
+
+	// the bucket exists once the watcher is bound, the value is written
+	// until the watcher reports it
+	var deadline = time.After(10 * time.Second)
+	for {
+		if err := k.Set(context.Background(), "greeting", "hello", 0); err != nil {
+			t.Logf("set: %v", err)
+		}
+
+		select {
+		case event := <-svc.mEvents:
+			if event.Metadata.Params["key"] != "greeting" {
+				t.Fatalf("unexpected key %q", event.Metadata.Params["key"])
+			}
+			return
+		case <-time.After(200 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("watcher did not bind after the server started")
+		}
+	}
+}
@@ -0,0 +1,3 @@
+package kvwatch
+
+const Name = "abesh_nats_kvwatch"
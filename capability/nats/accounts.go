@@ -0,0 +1,186 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+var ErrSystemAccountNotDefined = errors.New("accounts: system_account is not listed in accounts")
+var ErrSystemAccountJetStream = errors.New("accounts: jetstream can not be enabled on the system account")
+var ErrAccountJetStreamNotEnabled = errors.New("accounts: jetstream must be enabled on the server to enable it on an account")
+
+// accountOptions reads the accounts named by `accounts`, each one is read
+// from `account_<name>_*` keys, the users and nkeys listed by
+// `account_<name>_users` and `account_<name>_nkeys` are bound to it, the
+// jetstream limits of every account are returned by name since they can
+// only be applied once the server runs
+func accountOptions(cm model.ConfigMap, users []*server.User, nkeyUsers []*server.NkeyUser) ([]*server.Account, map[string]map[string]server.JetStreamAccountLimits, error) {
+	var names = stringList(cm, "accounts")
+	if len(names) == 0 {
+		return nil, nil, nil
+	}
+
+	var accounts = make([]*server.Account, 0, len(names))
+	var byName = make(map[string]*server.Account, len(names))
+	for _, name := range names {
+		if _, ok := byName[name]; ok {
+			return nil, nil, fmt.Errorf("accounts: account %q listed twice", name)
+		}
+		var account = server.NewAccount(name)
+		accounts = append(accounts, account)
+		byName[name] = account
+	}
+
+	var systemAccount = cm.String("system_account", "")
+	if len(systemAccount) != 0 && systemAccount != server.DEFAULT_SYSTEM_ACCOUNT {
+		if _, ok := byName[systemAccount]; !ok {
+			return nil, nil, ErrSystemAccountNotDefined
+		}
+	}
+
+	if err := bindUsers(cm, byName, users, nkeyUsers); err != nil {
+		return nil, nil, err
+	}
+
+	// exports are added first since imports are checked against them
+	for _, account := range accounts {
+		var prefix = "account_" + account.Name + "_"
+		for _, subject := range stringList(cm, prefix+"stream_exports") {
+			if err := account.AddStreamExport(subject, nil); err != nil {
+				return nil, nil, fmt.Errorf("accounts: %sstream_exports: %v", prefix, err)
+			}
+		}
+		for _, subject := range stringList(cm, prefix+"service_exports") {
+			if err := account.AddServiceExport(subject, nil); err != nil {
+				return nil, nil, fmt.Errorf("accounts: %sservice_exports: %v", prefix, err)
+			}
+		}
+	}
+
+	for _, account := range accounts {
+		var prefix = "account_" + account.Name + "_"
+		for _, item := range stringList(cm, prefix+"stream_imports") {
+			from, subject, err := accountImport(byName, prefix+"stream_imports", item)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err = account.AddStreamImport(from, subject, ""); err != nil {
+				return nil, nil, fmt.Errorf("accounts: %sstream_imports: %v", prefix, err)
+			}
+		}
+		for _, item := range stringList(cm, prefix+"service_imports") {
+			from, subject, err := accountImport(byName, prefix+"service_imports", item)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err = account.AddServiceImport(from, subject, subject); err != nil {
+				return nil, nil, fmt.Errorf("accounts: %sservice_imports: %v", prefix, err)
+			}
+		}
+	}
+
+	var jetStreamLimits = make(map[string]map[string]server.JetStreamAccountLimits)
+	for _, account := range accounts {
+		var prefix = "account_" + account.Name + "_"
+		if !cm.Bool(prefix+"jetstream", false) {
+			continue
+		}
+		if account.Name == systemAccount {
+			return nil, nil, ErrSystemAccountJetStream
+		}
+		if !cm.Bool("jetstream", false) {
+			return nil, nil, ErrAccountJetStreamNotEnabled
+		}
+		jetStreamLimits[account.Name] = map[string]server.JetStreamAccountLimits{
+			"": {
+				MaxMemory:            cm.Int64(prefix+"jetstream_max_memory", -1),
+				MaxStore:             cm.Int64(prefix+"jetstream_max_store", -1),
+				MaxStreams:           cm.Int(prefix+"jetstream_max_streams", -1),
+				MaxConsumers:         cm.Int(prefix+"jetstream_max_consumers", -1),
+				MaxAckPending:        cm.Int(prefix+"jetstream_max_ack_pending", -1),
+				MemoryMaxStreamBytes: cm.Int64(prefix+"jetstream_memory_max_stream_bytes", -1),
+				StoreMaxStreamBytes:  cm.Int64(prefix+"jetstream_store_max_stream_bytes", -1),
+				MaxBytesRequired:     cm.Bool(prefix+"jetstream_max_bytes_required", false),
+			},
+		}
+	}
+
+	return accounts, jetStreamLimits, nil
+}
+
+// bindUsers binds the users and nkeys listed by every account,
+// a user can belong to a single account only
+func bindUsers(cm model.ConfigMap, byName map[string]*server.Account, users []*server.User, nkeyUsers []*server.NkeyUser) error {
+	var usersByName = make(map[string]*server.User, len(users))
+	for _, user := range users {
+		usersByName[user.Username] = user
+	}
+
+	var nkeyUsersByKey = make(map[string]*server.NkeyUser, len(nkeyUsers))
+	for _, nkeyUser := range nkeyUsers {
+		nkeyUsersByKey[nkeyUser.Nkey] = nkeyUser
+	}
+
+	for name, account := range byName {
+		var prefix = "account_" + name + "_"
+		for _, username := range stringList(cm, prefix+"users") {
+			user, ok := usersByName[username]
+			if !ok {
+				return fmt.Errorf("accounts: %susers: user %q is not listed in users", prefix, username)
+			}
+			if user.Account != nil {
+				return fmt.Errorf("accounts: user %q is bound to accounts %q and %q", username, user.Account.Name, name)
+			}
+			user.Account = account
+		}
+
+		for _, nkey := range stringList(cm, prefix+"nkeys") {
+			nkeyUser, ok := nkeyUsersByKey[nkey]
+			if !ok {
+				return fmt.Errorf("accounts: %snkeys: nkey %q is not listed in nkeys", prefix, nkey)
+			}
+			if nkeyUser.Account != nil {
+				return fmt.Errorf("accounts: nkey %q is bound to accounts %q and %q", nkey, nkeyUser.Account.Name, name)
+			}
+			nkeyUser.Account = account
+		}
+	}
+
+	return nil
+}
+
+// accountImport splits an `<account>:<subject>` import item
+func accountImport(byName map[string]*server.Account, key string, item string) (*server.Account, string, error) {
+	var parts = strings.SplitN(item, ":", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return nil, "", fmt.Errorf("accounts: %s: %q is not an <account>:<subject> import", key, item)
+	}
+
+	account, ok := byName[parts[0]]
+	if !ok {
+		return nil, "", fmt.Errorf("accounts: %s: account %q is not listed in accounts", key, parts[0])
+	}
+
+	return account, parts[1], nil
+}
+
+// enableAccountJetStream applies the configured jetstream limits to
+// the accounts registered by the running server
+func (n *Nats) enableAccountJetStream() error {
+	for name, limits := range n.mAccountJetStreamLimits {
+		account, err := n.mServer.LookupAccount(name)
+		if err != nil {
+			return err
+		}
+
+		if err = account.EnableJetStream(limits); err != nil {
+			return fmt.Errorf("accounts: %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nkeys"
+)
+
+var ErrUsersWithUsername = errors.New("auth: users and nkeys can not be combined with username or authorization")
+
+var connectionTypes = map[string]struct{}{
+	jwt.ConnectionTypeStandard:   {},
+	jwt.ConnectionTypeWebsocket:  {},
+	jwt.ConnectionTypeLeafnode:   {},
+	jwt.ConnectionTypeLeafnodeWS: {},
+	jwt.ConnectionTypeMqtt:       {},
+	jwt.ConnectionTypeMqttWS:     {},
+}
+
+// userOptions reads the users named by `users` and the public keys listed
+// by `nkeys`, each user is read from `user_<username>_*` keys and each
+// nkey from `nkey_<public key>_*` keys, a password starting with `$2a$`
+// is compared as a bcrypt hash by the server
+func userOptions(cm model.ConfigMap) ([]*server.User, []*server.NkeyUser, error) {
+	var users []*server.User
+	for _, username := range stringList(cm, "users") {
+		var prefix = "user_" + username + "_"
+
+		var password = cm.String(prefix+"password", "")
+		if len(password) == 0 {
+			return nil, nil, fmt.Errorf("auth: %spassword not defined", prefix)
+		}
+
+		allowedConnectionTypes, err := allowedConnectionTypes(cm, prefix)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		users = append(users, &server.User{
+			Username:               username,
+			Password:               password,
+			Permissions:            permissions(cm, prefix),
+			AllowedConnectionTypes: allowedConnectionTypes,
+		})
+	}
+
+	var nkeyUsers []*server.NkeyUser
+	for _, nkey := range stringList(cm, "nkeys") {
+		var prefix = "nkey_" + nkey + "_"
+
+		if !nkeys.IsValidPublicUserKey(nkey) {
+			return nil, nil, fmt.Errorf("auth: nkeys: %q is not a public user key", nkey)
+		}
+
+		allowedConnectionTypes, err := allowedConnectionTypes(cm, prefix)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		nkeyUsers = append(nkeyUsers, &server.NkeyUser{
+			Nkey:                   nkey,
+			Permissions:            permissions(cm, prefix),
+			AllowedConnectionTypes: allowedConnectionTypes,
+		})
+	}
+
+	var single = len(cm.String("username", "")) != 0 || len(cm.String("authorization", "")) != 0
+	if single && (len(users) != 0 || len(nkeyUsers) != 0) {
+		return nil, nil, ErrUsersWithUsername
+	}
+
+	return users, nkeyUsers, nil
+}
+
+// permissions reads the `<prefix>publish_*` and `<prefix>subscribe_*`
+// subject lists along with the response permission, nil is returned
+// when the user is not restricted
+func permissions(cm model.ConfigMap, prefix string) *server.Permissions {
+	var p = &server.Permissions{
+		Publish:   subjectPermission(cm, prefix+"publish"),
+		Subscribe: subjectPermission(cm, prefix+"subscribe"),
+	}
+
+	if cm.Bool(prefix+"allow_responses", false) {
+		p.Response = &server.ResponsePermission{
+			MaxMsgs: cm.Int(prefix+"allow_responses_max", server.DEFAULT_ALLOW_RESPONSE_MAX_MSGS),
+			Expires: cm.Duration(prefix+"allow_responses_expires", server.DEFAULT_ALLOW_RESPONSE_EXPIRATION),
+		}
+	}
+
+	if p.Publish == nil && p.Subscribe == nil && p.Response == nil {
+		return nil
+	}
+
+	return p
+}
+
+// allowedConnectionTypes reads `<prefix>allowed_connection_types`, all
+// connection types are allowed when it is absent
+func allowedConnectionTypes(cm model.ConfigMap, prefix string) (map[string]struct{}, error) {
+	var list = stringList(cm, prefix+"allowed_connection_types")
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	var types = make(map[string]struct{}, len(list))
+	for _, item := range list {
+		item = strings.ToUpper(item)
+		if _, ok := connectionTypes[item]; !ok {
+			return nil, fmt.Errorf("auth: %sallowed_connection_types: unknown connection type %q", prefix, item)
+		}
+		types[item] = struct{}{}
+	}
+
+	return types, nil
+}
@@ -0,0 +1,73 @@
+package nats
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+var ErrClusterPasswordNotDefined = errors.New("cluster: cluster_password not defined for cluster_username")
+var ErrClusterPortNotDefined = errors.New("cluster: cluster_port not defined for cluster_routes")
+
+// clusterOptions reads the `cluster_*` keys into the route listener
+// options and the routes to solicit, cluster_routes falls back to routes_str
+func clusterOptions(cm model.ConfigMap) (server.ClusterOpts, []*url.URL, error) {
+	var cluster = server.ClusterOpts{
+		Name:           cm.String("cluster_name", ""),
+		Host:           cm.String("cluster_host", ""),
+		Username:       cm.String("cluster_username", ""),
+		Password:       cm.String("cluster_password", ""),
+		AuthTimeout:    cm.Float64("cluster_auth_timeout", 0),
+		Advertise:      cm.String("cluster_advertise", ""),
+		NoAdvertise:    cm.Bool("cluster_no_advertise", false),
+		ConnectRetries: cm.Int("cluster_connect_retries", 0),
+	}
+
+	var err error
+	if cluster.Port, err = port(cm, "cluster_port"); err != nil {
+		return server.ClusterOpts{}, nil, err
+	}
+
+	if len(cluster.Username) != 0 && len(cluster.Password) == 0 {
+		return server.ClusterOpts{}, nil, ErrClusterPasswordNotDefined
+	}
+
+	var importPermission = subjectPermission(cm, "cluster_import")
+	var exportPermission = subjectPermission(cm, "cluster_export")
+	if importPermission != nil || exportPermission != nil {
+		cluster.Permissions = &server.RoutePermissions{
+			Import: importPermission,
+			Export: exportPermission,
+		}
+	}
+
+	config, opts, err := tlsConfig(cm, "cluster_")
+	if err != nil {
+		return server.ClusterOpts{}, nil, err
+	}
+	if config != nil {
+		cluster.TLSConfig = config
+		cluster.TLSTimeout = opts.Timeout
+		cluster.TLSMap = opts.Map
+		cluster.TLSPinnedCerts = opts.PinnedCerts
+	}
+
+	var key = "cluster_routes"
+	if _, ok := cm[key]; !ok {
+		key = "routes_str"
+	}
+
+	routes, err := urlList(cm, key)
+	if err != nil {
+		return server.ClusterOpts{}, nil, err
+	}
+
+	// routes are solicited from the route listener
+	if len(routes) != 0 && cluster.Port == 0 {
+		return server.ClusterOpts{}, nil, ErrClusterPortNotDefined
+	}
+
+	return cluster, routes, nil
+}
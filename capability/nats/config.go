@@ -0,0 +1,189 @@
+package nats
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// stringList splits the comma separated value of key, blank items are dropped
+func stringList(cm model.ConfigMap, key string) []string {
+	var list []string
+	for _, item := range cm.StringList(key, ",", nil) {
+		if item = strings.TrimSpace(item); len(item) != 0 {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// subjectPermission reads the `<prefix>_allow` and `<prefix>_deny` subject
+// lists, nil is returned when neither is set
+func subjectPermission(cm model.ConfigMap, prefix string) *server.SubjectPermission {
+	var allow = stringList(cm, prefix+"_allow")
+	var deny = stringList(cm, prefix+"_deny")
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+
+	return &server.SubjectPermission{Allow: allow, Deny: deny}
+}
+
+// urlList parses the comma separated urls of key, every url
+// must name a host
+func urlList(cm model.ConfigMap, key string) ([]*url.URL, error) {
+	var urls []*url.URL
+	for _, item := range stringList(cm, key) {
+		u, err := url.Parse(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid url %q: %v", key, item, err)
+		}
+		if len(u.Hostname()) == 0 {
+			return nil, fmt.Errorf("%s: url %q has no host", key, item)
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+// port reads the listen port of key, -1 picks a random port
+func port(cm model.ConfigMap, key string) (int, error) {
+	var p = cm.Int(key, 0)
+	if p < -1 || p > 65535 {
+		return 0, fmt.Errorf("%s: %w %d", key, ErrInvalidPort, p)
+	}
+	return p, nil
+}
+
+// optionKeys names the config map keys each server option is read from,
+// a key ending with `_` matches every key with that prefix and a key
+// starting with `!` excludes a key matched by a prefix
+var optionKeys = map[string][]string{
+	"ConfigFile":            {"config_file"},
+	"ServerName":            {"server_name"},
+	"Host":                  {"host"},
+	"Port":                  {"port"},
+	"ClientAdvertise":       {"client_advertise"},
+	"Trace":                 {"trace"},
+	"Debug":                 {"debug"},
+	"TraceVerbose":          {"trace_verbose"},
+	"NoLog":                 {"no_log"},
+	"NoSigs":                {"no_sigs"},
+	"NoSublistCache":        {"no_sublist_cache"},
+	"NoHeaderSupport":       {"no_header_support"},
+	"DisableShortFirstPing": {"disable_short_first_ping"},
+	"Logtime":               {"logtime"},
+	"MaxConn":               {"max_connections"},
+	"MaxSubs":               {"max_subscriptions"},
+	"MaxSubTokens":          {"max_sub_tokens"},
+	"Nkeys":                 {"nkeys", "nkey_", "accounts", "account_"},
+	"Users":                 {"users", "user_", "accounts", "account_"},
+	"Accounts":              {"accounts", "account_"},
+	"NoAuthUser":            {"no_auth_user"},
+	"SystemAccount":         {"system_account", "operator"},
+	"NoSystemAccount":       {"no_system_account"},
+	"Username":              {"username"},
+	"Password":              {"password"},
+	"Authorization":         {"authorization"},
+	"PingInterval":          {"ping_interval"},
+	"MaxPingsOut":           {"max_pings_out"},
+	"HTTPHost":              {"http_host"},
+	"HTTPPort":              {"http_port"},
+	"HTTPBasePath":          {"http_base_path"},
+	"HTTPSPort":             {"https_port"},
+	"AuthTimeout":           {"auth_timeout"},
+	"MaxControlLine":        {"max_control_line"},
+	"MaxPayload":            {"max_payload"},
+	"MaxPending":            {"max_pending"},
+	"Cluster":               {"cluster_", "!cluster_routes"},
+	"Gateway":               {"gateway_"},
+	"LeafNode":              {"leafnode_"},
+	"JetStream":             {"jetstream"},
+	"JetStreamMaxMemory":    {"jetstream_max_memory"},
+	"JetStreamMaxStore":     {"jetstream_max_store"},
+	"JetStreamDomain":       {"jetstream_domain"},
+	"JetStreamExtHint":      {"jetstream_ext_hint"},
+	"JetStreamKey":          {"jetstream_key"},
+	"JetStreamUniqueTag":    {"jetstream_unique_tag"},
+	"StoreDir":              {"store_dir"},
+	"JsAccDefaultDomain":    {"js_acc_default_domain"},
+	"Websocket":             {"websocket_"},
+	"MQTT":                  {"mqtt_"},
+	"ProfPort":              {"prof_port"},
+	"PidFile":               {"pid_file"},
+	"PortsFileDir":          {"ports_file_dir"},
+	"LogFile":               {"log_file"},
+	"LogSizeLimit":          {"log_size_limit"},
+	"Syslog":                {"syslog"},
+	"RemoteSyslog":          {"remote_syslog"},
+	"Routes":                {"cluster_routes", "routes_str"},
+	"RoutesStr":             {"routes_str"},
+	"TLSTimeout":            {"tls_timeout"},
+	"TLS":                   {"tls", "tls_cert", "tls_key"},
+	"TLSVerify":             {"tls_verify", "tls", "tls_cert", "tls_key"},
+	"TLSMap":                {"tls_map", "tls", "tls_cert", "tls_key"},
+	"TLSCert":               {"tls_cert"},
+	"TLSKey":                {"tls_key"},
+	"TLSCaCert":             {"tls_ca_cert"},
+	"TLSConfig":             {"tls", "tls_cert", "tls_key"},
+	"TLSPinnedCerts":        {"tls", "tls_cert", "tls_key"},
+	"TLSRateLimit":          {"tls_rate_limit"},
+	"AllowNonTLS":           {"allow_non_tls"},
+	"WriteDeadline":         {"write_deadline"},
+	"MaxClosedClients":      {"max_closed_clients"},
+	"LameDuckDuration":      {"lame_duck_duration"},
+	"LameDuckGracePeriod":   {"lame_duck_grace_period"},
+	"MaxTracedMsgLen":       {"max_traced_msg_len"},
+	"TrustedKeys":           {"trusted_keys"},
+	"TrustedOperators":      {"operator"},
+	"AccountResolver":       {"operator", "resolver", "resolver_"},
+	"AlwaysEnableNonce":     {"always_enable_nonce"},
+	"CheckConfig":           {"check_config"},
+	"ConnectErrorReports":   {"connect_error_reports"},
+	"ReconnectErrorReports": {"reconnect_error_reports"},
+	"OCSPConfig":            {"ocsp", "ocsp_"},
+}
+
+// keySet reports whether cm holds one of keys, see optionKeys
+func keySet(cm model.ConfigMap, keys []string) bool {
+	for key := range cm {
+		var matched, excluded bool
+		for _, k := range keys {
+			switch {
+			case strings.HasPrefix(k, "!"):
+				excluded = excluded || key == k[1:]
+			case strings.HasSuffix(k, "_"):
+				matched = matched || strings.HasPrefix(key, k)
+			default:
+				matched = matched || key == k
+			}
+		}
+		if matched && !excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// overlay copies the options set in cm over the ones read from
+// config_file, an option is copied when one of its keys is present even
+// if its value is zero, nested blocks such as cluster are replaced as a
+// whole
+func overlay(file *server.Options, manifest *server.Options, cm model.ConfigMap) *server.Options {
+	var merged = *file
+	var dst = reflect.ValueOf(&merged).Elem()
+	var src = reflect.ValueOf(manifest).Elem()
+
+	for name, keys := range optionKeys {
+		if !keySet(cm, keys) {
+			continue
+		}
+		dst.FieldByName(name).Set(src.FieldByName(name))
+	}
+
+	return &merged
+}
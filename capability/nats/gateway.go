@@ -0,0 +1,98 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+var ErrGatewayNameNotDefined = errors.New("gateway: gateway_name not defined")
+var ErrGatewayPasswordNotDefined = errors.New("gateway: gateway_password not defined for gateway_username")
+var ErrGatewayClusterNameMismatch = errors.New("gateway: gateway_name must match cluster_name")
+
+// gatewayOptions reads the `gateway_*` keys, the remote gateways are named
+// by gateway_remotes and each one is read from `gateway_remote_<name>_*`
+// keys, such as gateway_remote_east_urls
+func gatewayOptions(cm model.ConfigMap) (server.GatewayOpts, error) {
+	var gateway = server.GatewayOpts{
+		Name:           cm.String("gateway_name", ""),
+		Host:           cm.String("gateway_host", ""),
+		Username:       cm.String("gateway_username", ""),
+		Password:       cm.String("gateway_password", ""),
+		AuthTimeout:    cm.Float64("gateway_auth_timeout", 0),
+		Advertise:      cm.String("gateway_advertise", ""),
+		ConnectRetries: cm.Int("gateway_connect_retries", 0),
+		RejectUnknown:  cm.Bool("gateway_reject_unknown_cluster", false),
+	}
+
+	var err error
+	if gateway.Port, err = port(cm, "gateway_port"); err != nil {
+		return server.GatewayOpts{}, err
+	}
+
+	var remotes = stringList(cm, "gateway_remotes")
+	if len(gateway.Name) == 0 {
+		if gateway.Port != 0 || len(remotes) != 0 {
+			return server.GatewayOpts{}, ErrGatewayNameNotDefined
+		}
+		return gateway, nil
+	}
+
+	// the server joins the super cluster under its cluster name
+	var clusterName = cm.String("cluster_name", "")
+	if len(clusterName) != 0 && clusterName != gateway.Name {
+		return server.GatewayOpts{}, ErrGatewayClusterNameMismatch
+	}
+
+	if len(gateway.Username) != 0 && len(gateway.Password) == 0 {
+		return server.GatewayOpts{}, ErrGatewayPasswordNotDefined
+	}
+
+	config, opts, err := tlsConfig(cm, "gateway_")
+	if err != nil {
+		return server.GatewayOpts{}, err
+	}
+	if config != nil {
+		gateway.TLSConfig = config
+		gateway.TLSTimeout = opts.Timeout
+		gateway.TLSMap = opts.Map
+		gateway.TLSPinnedCerts = opts.PinnedCerts
+	}
+
+	for _, name := range remotes {
+		remote, err := remoteGatewayOptions(cm, name)
+		if err != nil {
+			return server.GatewayOpts{}, err
+		}
+		gateway.Gateways = append(gateway.Gateways, remote)
+	}
+
+	return gateway, nil
+}
+
+func remoteGatewayOptions(cm model.ConfigMap, name string) (*server.RemoteGatewayOpts, error) {
+	var prefix = "gateway_remote_" + name + "_"
+
+	urls, err := urlList(cm, prefix+"urls")
+	if err != nil {
+		return nil, err
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("gateway: %surls not defined", prefix)
+	}
+
+	var remote = &server.RemoteGatewayOpts{Name: name, URLs: urls}
+
+	config, opts, err := tlsConfig(cm, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		remote.TLSConfig = config
+		remote.TLSTimeout = opts.Timeout
+	}
+
+	return remote, nil
+}
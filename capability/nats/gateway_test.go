@@ -0,0 +1,109 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats.go"
+)
+
+func startGatewayServer(t *testing.T, cm model.ConfigMap) *Nats {
+	t.Helper()
+
+	var n = &Nats{}
+	if err := n.SetConfigMap(cm); err != nil {
+		t.Fatalf("set config map: %v", err)
+	}
+	if err := n.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := n.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	t.Cleanup(func() {
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := n.Stop(ctx); err != nil {
+			t.Errorf("stop: %v", err)
+		}
+	})
+
+	return n
+}
+
+func TestGatewayDelivery(t *testing.T) {
+	var east = startGatewayServer(t, model.ConfigMap{
+		"server_name":  "east",
+		"host":         "127.0.0.1",
+		"port":         "-1",
+		"gateway_name": "east",
+		"gateway_host": "127.0.0.1",
+		"gateway_port": "-1",
+	})
+
+	var west = startGatewayServer(t, model.ConfigMap{
+		"server_name":              "west",
+		"host":                     "127.0.0.1",
+		"port":                     "-1",
+		"gateway_name":             "west",
+		"gateway_host":             "127.0.0.1",
+		"gateway_port":             "-1",
+		"gateway_remotes":          "east",
+		"gateway_remote_east_urls": fmt.Sprintf("nats://%s", east.mServer.GatewayAddr()),
+	})
+
+	var deadline = time.Now().Add(5 * time.Second)
+	for east.mServer.NumOutboundGateways() != 1 || west.mServer.NumOutboundGateways() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("gateways not connected: east %d, west %d",
+				east.mServer.NumOutboundGateways(), west.mServer.NumOutboundGateways())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sub, err := nats.Connect(east.mServer.ClientURL())
+	if err != nil {
+		t.Fatalf("connect east: %v", err)
+	}
+	defer sub.Close()
+
+	pub, err := nats.Connect(west.mServer.ClientURL())
+	if err != nil {
+		t.Fatalf("connect west: %v", err)
+	}
+	defer pub.Close()
+
+	subscription, err := sub.SubscribeSync("gateway.test")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := sub.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	if err := pub.Publish("gateway.test", []byte("hello")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	msg, err := subscription.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("message not delivered across the gateway: %v", err)
+	}
+	if string(msg.Data) != "hello" {
+		t.Fatalf("unexpected message %q", msg.Data)
+	}
+
+	// stopping one side must leave the other one running
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := west.Stop(ctx); err != nil {
+		t.Fatalf("stop west: %v", err)
+	}
+	if !east.mServer.Running() {
+		t.Fatal("east stopped together with west")
+	}
+}
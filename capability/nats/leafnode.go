@@ -0,0 +1,111 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+var ErrLeafNodePasswordNotDefined = errors.New("leafnode: leafnode_password not defined for leafnode_username")
+var ErrServerNotSetup = errors.New("server not set up")
+
+// leafNodeOptions reads the `leafnode_*` keys of the leaf node listener,
+// the remotes to connect out to are named by leafnode_remotes and each
+// one is read from `leafnode_remote_<name>_*` keys, such as
+// leafnode_remote_hub_urls
+func leafNodeOptions(cm model.ConfigMap) (server.LeafNodeOpts, error) {
+	var leafNode = server.LeafNodeOpts{
+		Host:              cm.String("leafnode_host", ""),
+		Username:          cm.String("leafnode_username", ""),
+		Password:          cm.String("leafnode_password", ""),
+		Account:           cm.String("leafnode_account", ""),
+		AuthTimeout:       cm.Float64("leafnode_auth_timeout", 0),
+		Advertise:         cm.String("leafnode_advertise", ""),
+		NoAdvertise:       cm.Bool("leafnode_no_advertise", false),
+		ReconnectInterval: cm.Duration("leafnode_reconnect_interval", 0),
+		MinVersion:        cm.String("leafnode_min_version", ""),
+	}
+
+	var err error
+	if leafNode.Port, err = port(cm, "leafnode_port"); err != nil {
+		return server.LeafNodeOpts{}, err
+	}
+
+	if len(leafNode.Username) != 0 && len(leafNode.Password) == 0 {
+		return server.LeafNodeOpts{}, ErrLeafNodePasswordNotDefined
+	}
+
+	config, opts, err := tlsConfig(cm, "leafnode_")
+	if err != nil {
+		return server.LeafNodeOpts{}, err
+	}
+	if config != nil {
+		leafNode.TLSConfig = config
+		leafNode.TLSTimeout = opts.Timeout
+		leafNode.TLSMap = opts.Map
+		leafNode.TLSPinnedCerts = opts.PinnedCerts
+	}
+
+	for _, name := range stringList(cm, "leafnode_remotes") {
+		remote, err := remoteLeafOptions(cm, name)
+		if err != nil {
+			return server.LeafNodeOpts{}, err
+		}
+		leafNode.Remotes = append(leafNode.Remotes, remote)
+	}
+
+	return leafNode, nil
+}
+
+func remoteLeafOptions(cm model.ConfigMap, name string) (*server.RemoteLeafOpts, error) {
+	var prefix = "leafnode_remote_" + name + "_"
+
+	urls, err := urlList(cm, prefix+"urls")
+	if err != nil {
+		return nil, err
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("leafnode: %surls not defined", prefix)
+	}
+
+	var remote = &server.RemoteLeafOpts{
+		LocalAccount: cm.String(prefix+"account", ""),
+		NoRandomize:  cm.Bool(prefix+"no_randomize", false),
+		URLs:         urls,
+		Credentials:  cm.String(prefix+"credentials_file", ""),
+		TLS:          cm.Bool(prefix+"tls", false),
+		Hub:          cm.Bool(prefix+"hub", false),
+		DenyImports:  stringList(cm, prefix+"deny_imports"),
+		DenyExports:  stringList(cm, prefix+"deny_exports"),
+	}
+
+	if len(remote.Credentials) != 0 {
+		if _, err = os.Stat(remote.Credentials); err != nil {
+			return nil, fmt.Errorf("leafnode: %scredentials_file: %v", prefix, err)
+		}
+	}
+
+	config, opts, err := tlsConfig(cm, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		remote.TLS = true
+		remote.TLSConfig = config
+		remote.TLSTimeout = opts.Timeout
+	}
+
+	return remote, nil
+}
+
+// Leafz reports the leaf node connections of the running server
+func (n *Nats) Leafz(opts *server.LeafzOptions) (*server.Leafz, error) {
+	if n.mServer == nil {
+		return nil, ErrServerNotSetup
+	}
+
+	return n.mServer.Leafz(opts)
+}
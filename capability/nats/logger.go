@@ -0,0 +1,83 @@
+package nats
+
+import (
+	"fmt"
+
+	"github.com/mkawserm/abesh/logger"
+	"github.com/nats-io/nats-server/v2/server"
+	"go.uber.org/zap"
+)
+
+// zapLogger routes the server logs through the zap logger of the
+// capability, fatal errors are logged at error level without exiting
+// so the abesh runtime stays in charge of the process
+type zapLogger struct {
+	mLogger *zap.Logger
+}
+
+func newZapLogger(contractId string, serverName string) *zapLogger {
+	return &zapLogger{
+		mLogger: logger.L(contractId).With(
+			zap.String("server_name", serverName),
+			zap.String("contract_id", contractId)),
+	}
+}
+
+func (l *zapLogger) Noticef(format string, v ...interface{}) {
+	l.mLogger.Info(fmt.Sprintf(format, v...))
+}
+
+func (l *zapLogger) Warnf(format string, v ...interface{}) {
+	l.mLogger.Warn(fmt.Sprintf(format, v...))
+}
+
+func (l *zapLogger) Fatalf(format string, v ...interface{}) {
+	l.mLogger.Error(fmt.Sprintf(format, v...), zap.Bool("fatal", true))
+}
+
+func (l *zapLogger) Errorf(format string, v ...interface{}) {
+	l.mLogger.Error(fmt.Sprintf(format, v...))
+}
+
+func (l *zapLogger) Debugf(format string, v ...interface{}) {
+	l.mLogger.Debug(fmt.Sprintf(format, v...))
+}
+
+func (l *zapLogger) Tracef(format string, v ...interface{}) {
+	l.mLogger.Debug(fmt.Sprintf(format, v...), zap.Bool("trace", true))
+}
+
+// configureLogger installs the zap logger on the server, debug and trace
+// logs are only emitted when enabled in opts, log_file and syslog keep
+// the server's own logger since they name where the logs must go
+func (n *Nats) configureLogger(opts *server.Options) {
+	if opts.NoLog {
+		return
+	}
+
+	if !logsToZap(opts) {
+		n.mServer.ConfigureLogger()
+		return
+	}
+
+	n.mServer.SetLoggerV2(newZapLogger(n.ContractId(), n.mServer.Name()),
+		opts.Debug, opts.Trace, opts.TraceVerbose)
+}
+
+// logsToZap reports whether configureLogger installs the zap logger
+func logsToZap(opts *server.Options) bool {
+	return len(opts.LogFile) == 0 && !opts.Syslog && len(opts.RemoteSyslog) == 0
+}
+
+// keepLogOptions copies the log options of running into opts, a reload
+// which changes them makes the server install its own stderr logger
+func keepLogOptions(opts *server.Options, running *server.Options) {
+	opts.Debug = running.Debug
+	opts.Trace = running.Trace
+	opts.TraceVerbose = running.TraceVerbose
+	opts.Logtime = running.Logtime
+	opts.LogFile = running.LogFile
+	opts.LogSizeLimit = running.LogSizeLimit
+	opts.Syslog = running.Syslog
+	opts.RemoteSyslog = running.RemoteSyslog
+}
@@ -0,0 +1,76 @@
+package nats
+
+import (
+	"errors"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+var ErrMQTTAckWaitNegative = errors.New("mqtt: mqtt_ack_wait must be positive")
+var ErrMQTTConsumerReplicas = errors.New("mqtt: mqtt_consumer_replicas can not be higher than mqtt_stream_replicas")
+var ErrMQTTJetStreamNotEnabled = errors.New("mqtt: jetstream must be enabled")
+var ErrMQTTServerNameNotDefined = errors.New("mqtt: server_name not defined")
+
+// mqttOptions reads the `mqtt_*` keys, the listener is
+// disabled without mqtt_port
+func mqttOptions(cm model.ConfigMap) (server.MQTTOpts, error) {
+	var mqtt = server.MQTTOpts{
+		Host:                  cm.String("mqtt_host", ""),
+		NoAuthUser:            cm.String("mqtt_no_auth_user", ""),
+		Username:              cm.String("mqtt_username", ""),
+		Password:              cm.String("mqtt_password", ""),
+		Token:                 cm.String("mqtt_token", ""),
+		JsDomain:              cm.String("mqtt_js_domain", ""),
+		StreamReplicas:        cm.Int("mqtt_stream_replicas", 0),
+		ConsumerReplicas:      cm.Int("mqtt_consumer_replicas", 0),
+		ConsumerMemoryStorage: cm.Bool("mqtt_consumer_memory_storage", false),
+		AuthTimeout:           cm.Float64("mqtt_auth_timeout", 0),
+		AckWait:               cm.Duration("mqtt_ack_wait", 0),
+		MaxAckPending:         cm.Uint16("mqtt_max_ack_pending", 0),
+	}
+
+	var err error
+	if mqtt.Port, err = port(cm, "mqtt_port"); err != nil {
+		return server.MQTTOpts{}, err
+	}
+
+	if mqtt.AckWait < 0 {
+		return server.MQTTOpts{}, ErrMQTTAckWaitNegative
+	}
+
+	if mqtt.ConsumerReplicas > 0 && mqtt.StreamReplicas > 0 && mqtt.ConsumerReplicas > mqtt.StreamReplicas {
+		return server.MQTTOpts{}, ErrMQTTConsumerReplicas
+	}
+
+	config, opts, err := tlsConfig(cm, "mqtt_")
+	if err != nil {
+		return server.MQTTOpts{}, err
+	}
+	if config != nil {
+		mqtt.TLSConfig = config
+		mqtt.TLSTimeout = opts.Timeout
+		mqtt.TLSMap = opts.Map
+		mqtt.TLSPinnedCerts = opts.PinnedCerts
+	}
+
+	return mqtt, nil
+}
+
+// checkMQTT ensures the server can persist mqtt sessions, the server
+// keeps them in jetstream streams tagged with its name
+func (n *Nats) checkMQTT() error {
+	if n.mMQTT.Port == 0 {
+		return nil
+	}
+
+	if !n.mJetStream {
+		return ErrMQTTJetStreamNotEnabled
+	}
+
+	if len(n.mServerName) == 0 {
+		return ErrMQTTServerNameNotDefined
+	}
+
+	return nil
+}
@@ -3,8 +3,8 @@ package nats
 import (
 	"context"
 	"crypto/tls"
-	"fmt"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/mkawserm/abesh/iface"
@@ -21,7 +21,15 @@ import (
 type Nats struct {
 	mCM               model.ConfigMap
 	mServer           *server.Server
+	mServerOptions    *server.Options
 	mEventTransmitter iface.IEventTransmitter
+	mReadyTimeout     time.Duration
+
+	mReloadMu                sync.Mutex
+	mReloadCancel            context.CancelFunc
+	mReloadWG                sync.WaitGroup
+	mReloadSignal            bool
+	mConfigFileWatchInterval time.Duration
 
 	/* Nats Server Options */
 	mConfigFile                 string
@@ -44,6 +52,7 @@ type Nats struct {
 	mNKeys                      []*server.NkeyUser
 	mUsers                      []*server.User
 	mAccounts                   []*server.Account
+	mAccountJetStreamLimits     map[string]map[string]server.JetStreamAccountLimits
 	mNoAuthUser                 string
 	mSystemAccount              string
 	mNoSystemAccount            bool
@@ -103,6 +112,7 @@ type Nats struct {
 	mTrustedKeys                []string
 	mTrustedOperators           []*jwt.OperatorClaims
 	mAccountResolver            server.AccountResolver
+	mResolverOptions            *resolverOptions
 	mAccountResolverTLSConfig   *tls.Config
 	mAlwaysEnableNonce          bool
 	mCustomClientAuthentication server.Authentication
@@ -141,6 +151,9 @@ func (n *Nats) GetConfigMap() model.ConfigMap {
 
 func (n *Nats) SetConfigMap(cm model.ConfigMap) error {
 	n.mCM = cm
+	n.mReadyTimeout = cm.Duration("ready_timeout", 10*time.Second)
+	n.mReloadSignal = cm.Bool("reload_signal", false)
+	n.mConfigFileWatchInterval = cm.Duration("config_file_watch_interval", 0)
 	n.mConfigFile = cm.String("config_file", "")
 	n.mServerName = cm.String("server_name", "")
 	n.mHost = cm.String("host", "")
@@ -158,9 +171,6 @@ func (n *Nats) SetConfigMap(cm model.ConfigMap) error {
 	n.mMaxConn = cm.Int("max_connections", 0)
 	n.mMaxSubs = cm.Int("max_subscriptions", 0)
 	n.mMaxSubTokens = cm.Uint8("max_sub_tokens", 0)
-	n.mNKeys = nil
-	n.mUsers = nil
-	n.mAccounts = nil
 	n.mNoAuthUser = cm.String("no_auth_user", "")
 	n.mSystemAccount = cm.String("system_account", "")
 	n.mNoSystemAccount = cm.Bool("no_system_account", false)
@@ -177,9 +187,6 @@ func (n *Nats) SetConfigMap(cm model.ConfigMap) error {
 	n.mMaxControlLine = cm.Int32("max_control_line", 0)
 	n.mMaxPayload = cm.Int32("max_payload", 0)
 	n.mMaxPending = cm.Int64("max_pending", 0)
-	n.mCluster = server.ClusterOpts{}
-	n.mGateway = server.GatewayOpts{}
-	n.mLeafNode = server.LeafNodeOpts{}
 	n.mJetStream = cm.Bool("jetstream", false)
 	n.mJetStreamMaxMemory = cm.Int64("jetstream_max_memory", 0)
 	n.mJetStreamMaxStore = cm.Int64("jetstream_max_store", 0)
@@ -190,8 +197,6 @@ func (n *Nats) SetConfigMap(cm model.ConfigMap) error {
 	n.mJetStreamLimits = server.JSLimitOpts{}
 	n.mStoreDir = cm.String("store_dir", "")
 	n.mJsAccDefaultDomain = cm.StringMap("js_acc_default_domain", nil)
-	n.mWebsocket = server.WebsocketOpts{}
-	n.mMQTT = server.MQTTOpts{}
 	n.mProfPort = cm.Int("prof_port", 0)
 	n.mPidFile = cm.String("pid_file", "")
 	n.mPortsFileDir = cm.String("ports_file_dir", "")
@@ -199,12 +204,11 @@ func (n *Nats) SetConfigMap(cm model.ConfigMap) error {
 	n.mLogSizeLimit = cm.Int64("log_size_limit", 0)
 	n.mSyslog = cm.Bool("syslog", false)
 	n.mRemoteSyslog = cm.String("remote_syslog", "")
-	n.mRoutes = nil
 	n.mRoutesStr = cm.String("routes_str", "")
 	n.mTLSTimeout = cm.Float64("tls_timeout", 0)
-	n.mTLSMap = cm.Bool("tls", false)
+	n.mTLS = cm.Bool("tls", false)
 	n.mTLSVerify = cm.Bool("tls_verify", false)
-	n.mTLSMap = cm.Bool("tls", false)
+	n.mTLSMap = cm.Bool("tls_map", false)
 	n.mTLSCert = cm.String("tls_cert", "")
 	n.mTLSKey = cm.String("tls_key", "")
 	n.mTLSCaCert = cm.String("tls_ca_cert", "")
@@ -218,7 +222,6 @@ func (n *Nats) SetConfigMap(cm model.ConfigMap) error {
 	n.mLameDuckGracePeriod = cm.Duration("lame_duck_grace_period", 0)
 	n.mMaxTracedMsgLen = cm.Int("max_traced_msg_len", 0)
 	n.mTrustedKeys = cm.StringList("trusted_keys", ",", []string{})
-	n.mTrustedOperators = nil
 	n.mAccountResolver = nil
 	n.mAccountResolverTLSConfig = nil
 	n.mAlwaysEnableNonce = cm.Bool("always_enable_nonce", false)
@@ -228,11 +231,94 @@ func (n *Nats) SetConfigMap(cm model.ConfigMap) error {
 	n.mConnectErrorReports = cm.Int("connect_error_reports", 0)
 	n.mReconnectErrorReports = cm.Int("reconnect_error_reports", 0)
 	n.mTags = nil
-	n.mOCSPConfig = nil
+
+	var err error
+	if n.mCluster, n.mRoutes, err = clusterOptions(cm); err != nil {
+		return err
+	}
+
+	if n.mGateway, err = gatewayOptions(cm); err != nil {
+		return err
+	}
+
+	if n.mLeafNode, err = leafNodeOptions(cm); err != nil {
+		return err
+	}
+
+	if n.mWebsocket, err = websocketOptions(cm); err != nil {
+		return err
+	}
+
+	if n.mMQTT, err = mqttOptions(cm); err != nil {
+		return err
+	}
+
+	if n.mUsers, n.mNKeys, err = userOptions(cm); err != nil {
+		return err
+	}
+
+	if n.mAccounts, n.mAccountJetStreamLimits, err = accountOptions(cm, n.mUsers, n.mNKeys); err != nil {
+		return err
+	}
+
+	if n.mTrustedOperators, n.mSystemAccount, n.mResolverOptions, err = operatorOptions(cm); err != nil {
+		return err
+	}
+
+	if n.mOCSPConfig, err = ocspConfig(cm); err != nil {
+		return err
+	}
+
+	if n.mConfigFileWatchInterval > 0 && len(n.mConfigFile) == 0 {
+		return ErrConfigFileNotDefined
+	}
+
+	// the config map does not change at runtime, a signal only has
+	// config_file to read again
+	if n.mReloadSignal && len(n.mConfigFile) == 0 {
+		return ErrReloadSignalWithoutConfigFile
+	}
+
+	if n.mReloadSignal && !n.mNoSigs {
+		return ErrReloadSignalWithSigs
+	}
+
 	return nil
 }
 
 func (n *Nats) Setup() error {
+	if n.mResolverOptions != nil && n.mAccountResolver == nil {
+		resolver, err := n.mResolverOptions.accountResolver()
+		if err != nil {
+			return &ConfigError{Err: err}
+		}
+		n.mAccountResolver = resolver
+	}
+
+	opts, err := n.options(n.mAccountResolver)
+	if err != nil {
+		return err
+	}
+
+	srv, err := server.NewServer(opts)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	n.mServer = srv
+	n.mServerOptions = opts
+	n.configureLogger(opts)
+	return nil
+}
+
+// options assembles the server options from the config map without
+// changing n, resolver is the account resolver to use, errors are
+// returned as *ConfigError
+func (n *Nats) options(resolver server.AccountResolver) (*server.Options, error) {
+	if err := n.checkMQTT(); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+
 	var opts = &server.Options{
 		ConfigFile:                 n.mConfigFile,
 		ServerName:                 n.mServerName,
@@ -312,7 +398,7 @@ func (n *Nats) Setup() error {
 		MaxTracedMsgLen:            n.mMaxTracedMsgLen,
 		TrustedKeys:                n.mTrustedKeys,
 		TrustedOperators:           n.mTrustedOperators,
-		AccountResolver:            n.mAccountResolver,
+		AccountResolver:            resolver,
 		AccountResolverTLSConfig:   n.mAccountResolverTLSConfig,
 		AlwaysEnableNonce:          n.mAlwaysEnableNonce,
 		CustomClientAuthentication: n.mCustomClientAuthentication,
@@ -324,26 +410,66 @@ func (n *Nats) Setup() error {
 		OCSPConfig:                 n.mOCSPConfig,
 	}
 
-	// Create the server with appropriate options.
-	srv, err := server.NewServer(opts)
-	if err != nil {
-		server.PrintAndDie(fmt.Sprintf("%s", err))
+	if err := n.clientTLSConfig(opts); err != nil {
+		return nil, &ConfigError{Err: err}
 	}
 
-	srv.ConfigureLogger()
-	n.mServer = srv
-	return nil
+	// config_file is the base, the options set in the config map win
+	if len(n.mConfigFile) != 0 {
+		fileOpts, err := server.ProcessConfigFile(n.mConfigFile)
+		if err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+		opts = overlay(fileOpts, opts, n.mCM)
+	}
+
+	if err := checkOptions(opts); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+
+	return opts, nil
 }
 
 func (n *Nats) Start(ctx context.Context) error {
 	n.mServer.Start()
+
+	if err := n.waitReady(); err != nil {
+		n.mServer.Shutdown()
+		return err
+	}
+
+	if err := n.enableAccountJetStream(); err != nil {
+		n.mServer.Shutdown()
+		return err
+	}
+
+	n.startReload()
 	return nil
 }
 
+// Stop shuts the server down and returns ctx.Err() when ctx is done
+// before the shutdown completes, lame duck mode is not supported since
+// nats-server 2.8.4 only enters it from its own SIGUSR2 handler, so the
+// lame_duck_* options are rejected
 func (n *Nats) Stop(ctx context.Context) error {
-	n.mServer.WaitForShutdown()
-	n.mServer.Shutdown()
-	return nil
+	if n.mServer == nil {
+		return nil
+	}
+
+	n.stopReload()
+
+	var done = make(chan struct{})
+	go func() {
+		defer close(done)
+		n.mServer.Shutdown()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (n *Nats) SetEventTransmitter(eventTransmitter iface.IEventTransmitter) error {
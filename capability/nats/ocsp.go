@@ -0,0 +1,53 @@
+package nats
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+var ocspModes = map[string]server.OCSPMode{
+	"auto":   server.OCSPModeAuto,
+	"always": server.OCSPModeAlways,
+	"must":   server.OCSPModeMust,
+	"never":  server.OCSPModeNever,
+}
+
+// ocspConfig reads `ocsp`, `ocsp_mode` and `ocsp_override_urls`, stapling
+// is left to the server defaults when none is set, `ocsp` alone enables
+// the auto mode like the server config file does
+func ocspConfig(cm model.ConfigMap) (*server.OCSPConfig, error) {
+	_, enabled := cm["ocsp"]
+	_, mode := cm["ocsp_mode"]
+	_, overrideURLs := cm["ocsp_override_urls"]
+	if !enabled && !mode && !overrideURLs {
+		return nil, nil
+	}
+
+	var config = &server.OCSPConfig{Mode: server.OCSPModeAuto}
+	if !cm.Bool("ocsp", true) {
+		config.Mode = server.OCSPModeNever
+	}
+
+	if mode {
+		var name = strings.ToLower(cm.String("ocsp_mode", ""))
+		m, ok := ocspModes[name]
+		if !ok {
+			return nil, fmt.Errorf("ocsp: unsupported ocsp_mode %q", name)
+		}
+		config.Mode = m
+	}
+
+	for _, item := range stringList(cm, "ocsp_override_urls") {
+		u, err := url.Parse(item)
+		if err != nil || len(u.Host) == 0 {
+			return nil, fmt.Errorf("ocsp: invalid ocsp_override_urls url %q", item)
+		}
+		config.OverrideURLs = append(config.OverrideURLs, item)
+	}
+
+	return config, nil
+}
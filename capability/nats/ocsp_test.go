@@ -0,0 +1,190 @@
+package nats
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats.go"
+	"golang.org/x/crypto/ocsp"
+)
+
+func writePEM(t *testing.T, path string, kind string, der []byte) {
+	t.Helper()
+
+	var data = pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+// ocspResponder answers every request with a good status signed by the ca
+func ocspResponder(ca *x509.Certificate, caKey crypto.Signer, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		der, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(r.URL.Path, "/"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, err := ocsp.ParseRequest(der)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var now = time.Now()
+		resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   now.Add(-time.Minute),
+			NextUpdate:   now.Add(time.Hour),
+		}, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		atomic.AddInt32(requests, 1)
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(resp)
+	}))
+}
+
+func TestOCSPStapling(t *testing.T) {
+	var dir = t.TempDir()
+	var now = time.Now()
+
+	var caKey = newKey(t)
+	caDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ocsp test ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ocsp test ca"},
+	}, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("parse ca: %v", err)
+	}
+
+	var leafKey = newKey(t)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create leaf: %v", err)
+	}
+	leafKeyDER, err := x509.MarshalECPrivateKey(leafKey)
+	if err != nil {
+		t.Fatalf("marshal leaf key: %v", err)
+	}
+
+	var caFile = filepath.Join(dir, "ca.pem")
+	var certFile = filepath.Join(dir, "cert.pem")
+	var keyFile = filepath.Join(dir, "key.pem")
+	writePEM(t, caFile, "CERTIFICATE", caDER)
+	writePEM(t, certFile, "CERTIFICATE", leafDER)
+	writePEM(t, keyFile, "EC PRIVATE KEY", leafKeyDER)
+
+	var requests int32
+	var responder = ocspResponder(ca, caKey, &requests)
+	defer responder.Close()
+
+	var n = &Nats{}
+	if err := n.SetConfigMap(model.ConfigMap{
+		"server_name":        "ocsp",
+		"host":               "127.0.0.1",
+		"port":               "-1",
+		"tls_cert":           certFile,
+		"tls_key":            keyFile,
+		"tls_ca_cert":        caFile,
+		"ocsp_mode":          "always",
+		"ocsp_override_urls": responder.URL,
+	}); err != nil {
+		t.Fatalf("set config map: %v", err)
+	}
+	if err := n.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := n.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer func() {
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = n.Stop(ctx)
+	}()
+
+	if atomic.LoadInt32(&requests) == 0 {
+		t.Fatal("ocsp responder was not queried")
+	}
+
+	var pool = x509.NewCertPool()
+	pool.AddCert(ca)
+
+	var staple []byte
+	nc, err := nats.Connect(n.mServer.ClientURL(), nats.Secure(&tls.Config{
+		RootCAs: pool,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			staple = state.OCSPResponse
+			return nil
+		},
+	}))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer nc.Close()
+
+	if len(staple) == 0 {
+		t.Fatal("no stapled ocsp response")
+	}
+	resp, err := ocsp.ParseResponseForCert(staple, nil, ca)
+	if err != nil {
+		t.Fatalf("parse stapled response: %v", err)
+	}
+	if resp.Status != ocsp.Good || resp.SerialNumber.Cmp(big.NewInt(2)) != 0 {
+		t.Fatalf("unexpected stapled response: status %d serial %s", resp.Status, resp.SerialNumber)
+	}
+}
@@ -0,0 +1,148 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nkeys"
+)
+
+const (
+	ResolverMemory = "memory"
+	ResolverFull   = "full"
+	ResolverCache  = "cache"
+)
+
+var ErrOperatorWithUsers = errors.New("operator: users, nkeys, accounts and username can not be combined with operator")
+var ErrResolverWithoutOperator = errors.New("operator: resolver requires operator")
+var ErrResolverDirNotDefined = errors.New("operator: resolver_dir or store_dir not defined")
+
+// resolverOptions holds the account resolver settings, the resolver
+// itself is built by Setup since the directory resolvers create their
+// directory on disk
+type resolverOptions struct {
+	kind         string
+	dir          string
+	limit        int64
+	syncInterval time.Duration
+	ttl          time.Duration
+	allowDelete  bool
+	preload      map[string]string
+}
+
+// operatorOptions reads the operator jwt files listed by `operator` and
+// the `resolver_*` keys, the system account defaults to the one named by
+// the first operator, resolver_preload lists account jwt files stored in
+// the resolver before the server starts
+func operatorOptions(cm model.ConfigMap) ([]*jwt.OperatorClaims, string, *resolverOptions, error) {
+	var systemAccount = cm.String("system_account", "")
+	var kind = strings.ToLower(cm.String("resolver", ""))
+
+	var files = stringList(cm, "operator")
+	if len(files) == 0 {
+		if len(kind) != 0 {
+			return nil, "", nil, ErrResolverWithoutOperator
+		}
+		return nil, systemAccount, nil, nil
+	}
+
+	var auth = len(stringList(cm, "users")) != 0 ||
+		len(stringList(cm, "nkeys")) != 0 ||
+		len(stringList(cm, "accounts")) != 0 ||
+		len(cm.String("username", "")) != 0 ||
+		len(cm.String("authorization", "")) != 0
+	if auth {
+		return nil, "", nil, ErrOperatorWithUsers
+	}
+
+	var operators = make([]*jwt.OperatorClaims, 0, len(files))
+	for _, file := range files {
+		claims, err := server.ReadOperatorJWT(file)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("operator: %s: %v", file, err)
+		}
+		operators = append(operators, claims)
+	}
+
+	if len(systemAccount) == 0 {
+		systemAccount = operators[0].SystemAccount
+	}
+	if len(systemAccount) != 0 && !nkeys.IsValidPublicAccountKey(systemAccount) {
+		return nil, "", nil, fmt.Errorf("operator: system_account %q is not a public account key", systemAccount)
+	}
+
+	var resolver = &resolverOptions{
+		kind:         kind,
+		dir:          cm.String("resolver_dir", ""),
+		limit:        cm.Int64("resolver_limit", 0),
+		syncInterval: cm.Duration("resolver_sync_interval", 0),
+		ttl:          cm.Duration("resolver_ttl", time.Minute*2),
+		allowDelete:  cm.Bool("resolver_allow_delete", false),
+		preload:      make(map[string]string),
+	}
+
+	switch resolver.kind {
+	case "":
+		resolver.kind = ResolverMemory
+	case ResolverMemory:
+	case ResolverFull, ResolverCache:
+		if len(resolver.dir) == 0 {
+			var storeDir = cm.String("store_dir", "")
+			if len(storeDir) == 0 {
+				return nil, "", nil, ErrResolverDirNotDefined
+			}
+			resolver.dir = filepath.Join(storeDir, "jwt")
+		}
+	default:
+		return nil, "", nil, fmt.Errorf("operator: unknown resolver %q", resolver.kind)
+	}
+
+	for _, file := range stringList(cm, "resolver_preload") {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("operator: resolver_preload: %v", err)
+		}
+
+		var accountJWT = strings.TrimSpace(string(data))
+		claims, err := jwt.DecodeAccountClaims(accountJWT)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("operator: resolver_preload: %s: %v", file, err)
+		}
+		resolver.preload[claims.Subject] = accountJWT
+	}
+
+	return operators, systemAccount, resolver, nil
+}
+
+// accountResolver builds the resolver and stores the preloaded accounts
+func (r *resolverOptions) accountResolver() (server.AccountResolver, error) {
+	var resolver server.AccountResolver
+	var err error
+
+	switch r.kind {
+	case ResolverFull:
+		resolver, err = server.NewDirAccResolver(r.dir, r.limit, r.syncInterval, r.allowDelete)
+	case ResolverCache:
+		resolver, err = server.NewCacheDirAccResolver(r.dir, r.limit, r.ttl)
+	default:
+		resolver = &server.MemAccResolver{}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("operator: %s resolver: %v", r.kind, err)
+	}
+
+	for account, accountJWT := range r.preload {
+		if err = resolver.Store(account, accountJWT); err != nil {
+			return nil, fmt.Errorf("operator: resolver_preload: %s: %v", account, err)
+		}
+	}
+
+	return resolver, nil
+}
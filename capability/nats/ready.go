@@ -0,0 +1,37 @@
+package nats
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrServerNotReady = errors.New("server not ready for connections within ready_timeout")
+var ErrJetStreamNotReady = errors.New("jetstream not enabled within ready_timeout")
+
+// jetStreamPollInterval is how often the jetstream state is checked while
+// waiting for the server to become ready
+const jetStreamPollInterval = 25 * time.Millisecond
+
+// waitReady blocks until the server accepts connections and, with jetstream
+// enabled, until jetstream is running, both within ready_timeout so the
+// capabilities started after this one can connect right away
+func (n *Nats) waitReady() error {
+	var deadline = time.Now().Add(n.mReadyTimeout)
+
+	if !n.mServer.ReadyForConnections(n.mReadyTimeout) {
+		return ErrServerNotReady
+	}
+
+	if !n.mJetStream {
+		return nil
+	}
+
+	for !n.mServer.JetStreamEnabled() {
+		if time.Now().After(deadline) {
+			return ErrJetStreamNotReady
+		}
+		time.Sleep(jetStreamPollInterval)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/mkawserm/abesh/logger"
+	"github.com/mkawserm/abesh/model"
+	"go.uber.org/zap"
+)
+
+var ErrResolverNotReloadable = errors.New("operator: resolver can not be changed at runtime")
+var ErrConfigFileNotDefined = errors.New("reload: config_file not defined for config_file_watch_interval")
+var ErrReloadSignalWithoutConfigFile = errors.New("reload: config_file not defined for reload_signal")
+var ErrReloadSignalWithSigs = errors.New("reload: reload_signal requires no_sigs, the server handles SIGHUP itself otherwise")
+
+// ReloadError is returned by Reload when the server refused the new
+// options, Err names the first option which can not be changed at runtime
+type ReloadError struct {
+	Err error
+}
+
+func (e *ReloadError) Error() string {
+	return "nats: reload: " + e.Err.Error()
+}
+
+func (e *ReloadError) Unwrap() error {
+	return e.Err
+}
+
+// Reload rebuilds the server options from cm, config_file is read again,
+// and applies them to the running server, users, permissions, accounts,
+// tls and log levels can be changed, listeners, jetstream storage and the
+// account resolver can not, with the zap logger a trace change only
+// applies to the connections made after the reload
+func (n *Nats) Reload(cm model.ConfigMap) error {
+	n.mReloadMu.Lock()
+	defer n.mReloadMu.Unlock()
+
+	if n.mServer == nil {
+		return ErrServerNotSetup
+	}
+
+	var next = &Nats{}
+	if err := next.SetConfigMap(cm); err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	// the running server keeps its account resolver
+	if !reflect.DeepEqual(next.mResolverOptions, n.mResolverOptions) {
+		return &ReloadError{Err: ErrResolverNotReloadable}
+	}
+	opts, err := next.options(n.mAccountResolver)
+	if err != nil {
+		return err
+	}
+
+	// zap keeps the server logs when the running log options are handed
+	// back, the new levels are set on the zap logger once applied
+	var logOpts = opts
+	if !opts.NoLog && logsToZap(opts) {
+		logOpts = opts.Clone()
+		keepLogOptions(opts, n.mServerOptions)
+	}
+
+	if err = n.mServer.ReloadOptions(opts); err != nil {
+		return &ReloadError{Err: err}
+	}
+
+	n.mServerOptions = opts
+	n.configureLogger(logOpts)
+
+	n.mCM = cm
+	n.mAccountJetStreamLimits = next.mAccountJetStreamLimits
+	return n.enableAccountJetStream()
+}
+
+func (n *Nats) reload(trigger string) {
+	n.mReloadMu.Lock()
+	var cm = n.mCM
+	n.mReloadMu.Unlock()
+
+	if err := n.Reload(cm); err != nil {
+		logger.L(n.ContractId()).Error(err.Error(), zap.String("trigger", trigger))
+		return
+	}
+
+	logger.L(n.ContractId()).Info("reloaded", zap.String("trigger", trigger))
+}
+
+// startReload watches config_file and SIGHUP when enabled, both reload
+// the server with the current config map, so only the changes made to
+// config_file are picked up
+func (n *Nats) startReload() {
+	if n.mConfigFileWatchInterval <= 0 && !n.mReloadSignal {
+		return
+	}
+
+	var ctx context.Context
+	ctx, n.mReloadCancel = context.WithCancel(context.Background())
+
+	if n.mConfigFileWatchInterval > 0 {
+		n.mReloadWG.Add(1)
+		go n.watchConfigFile(ctx, n.mConfigFile, n.mConfigFileWatchInterval)
+	}
+
+	if n.mReloadSignal {
+		n.mReloadWG.Add(1)
+		go n.watchReloadSignal(ctx)
+	}
+}
+
+func (n *Nats) stopReload() {
+	if n.mReloadCancel != nil {
+		n.mReloadCancel()
+	}
+
+	n.mReloadWG.Wait()
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// watchConfigFile polls the modification time of path, editors which
+// replace the file are handled as well since the path is stat'ed each time
+func (n *Nats) watchConfigFile(ctx context.Context, path string, interval time.Duration) {
+	defer n.mReloadWG.Done()
+
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last = modTime(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var current = modTime(path)
+		if current.IsZero() || current.Equal(last) {
+			continue
+		}
+		last = current
+
+		n.reload("config_file")
+	}
+}
+
+func (n *Nats) watchReloadSignal(ctx context.Context) {
+	defer n.mReloadWG.Done()
+
+	var c = make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	defer signal.Stop(c)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c:
+			n.reload("signal")
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package nats
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+var ErrTLSCertNotDefined = errors.New("tls: tls_cert and tls_key not defined")
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"curvep256": tls.CurveP256,
+	"curvep384": tls.CurveP384,
+	"curvep521": tls.CurveP521,
+	"x25519":    tls.X25519,
+}
+
+// tlsConfigOpts reads the `<prefix>tls_*` keys, prefix selects the listener
+// such as `cluster_` and is empty for the client listener, ciphers and
+// curves are given by their crypto/tls names
+func tlsConfigOpts(cm model.ConfigMap, prefix string) (*server.TLSConfigOpts, error) {
+	var opts = &server.TLSConfigOpts{
+		CertFile: cm.String(prefix+"tls_cert", ""),
+		KeyFile:  cm.String(prefix+"tls_key", ""),
+		CaFile:   cm.String(prefix+"tls_ca_cert", ""),
+		Verify:   cm.Bool(prefix+"tls_verify", false),
+		Insecure: cm.Bool(prefix+"tls_insecure", false),
+		Map:      cm.Bool(prefix+"tls_map", false),
+		Timeout:  cm.Float64(prefix+"tls_timeout", 0),
+	}
+
+	if cm.Bool(prefix+"tls_verify_and_map", false) {
+		opts.Verify = true
+		opts.Map = true
+	}
+
+	var ciphers = tlsCiphers()
+	for _, name := range stringList(cm, prefix+"tls_ciphers") {
+		id, ok := ciphers[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("%stls: unknown cipher %q", prefix, name)
+		}
+		opts.Ciphers = append(opts.Ciphers, id)
+	}
+
+	for _, name := range stringList(cm, prefix+"tls_curve_preferences") {
+		id, ok := tlsCurves[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("%stls: unknown curve %q", prefix, name)
+		}
+		opts.CurvePreferences = append(opts.CurvePreferences, id)
+	}
+
+	var pinnedCerts = stringList(cm, prefix+"tls_pinned_certs")
+	if len(pinnedCerts) != 0 {
+		opts.PinnedCerts = make(server.PinnedCertSet, len(pinnedCerts))
+		for _, pinnedCert := range pinnedCerts {
+			opts.PinnedCerts[strings.ToLower(pinnedCert)] = struct{}{}
+		}
+	}
+
+	return opts, nil
+}
+
+func tlsCiphers() map[string]uint16 {
+	var ciphers = make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		ciphers[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		ciphers[suite.Name] = suite.ID
+	}
+	return ciphers
+}
+
+// tlsConfig builds the tls config of the listener selected by prefix,
+// a nil config is returned when no certificate is configured
+func tlsConfig(cm model.ConfigMap, prefix string) (*tls.Config, *server.TLSConfigOpts, error) {
+	opts, err := tlsConfigOpts(cm, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(opts.CertFile) == 0 && len(opts.KeyFile) == 0 {
+		return nil, opts, nil
+	}
+
+	// name the offending key instead of the bare file error
+	for key, file := range map[string]string{
+		prefix + "tls_cert":    opts.CertFile,
+		prefix + "tls_key":     opts.KeyFile,
+		prefix + "tls_ca_cert": opts.CaFile,
+	} {
+		if len(file) == 0 {
+			continue
+		}
+		if _, err = os.ReadFile(file); err != nil {
+			return nil, nil, fmt.Errorf("%stls: %s: %v", prefix, key, err)
+		}
+	}
+
+	config, err := server.GenTLSConfig(opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%stls: %v", prefix, err)
+	}
+
+	var minVersion = cm.String(prefix+"tls_min_version", "")
+	if len(minVersion) != 0 {
+		version, ok := tlsVersions[minVersion]
+		if !ok {
+			return nil, nil, fmt.Errorf("%stls: unsupported tls_min_version %q", prefix, minVersion)
+		}
+		config.MinVersion = version
+	}
+
+	return config, opts, nil
+}
+
+// clientTLSConfig builds the tls config of the client listener into opts,
+// tls requires tls_cert and tls_key
+func (n *Nats) clientTLSConfig(opts *server.Options) error {
+	if !n.mTLS && len(n.mTLSCert) == 0 && len(n.mTLSKey) == 0 {
+		return nil
+	}
+
+	config, tlsOpts, err := tlsConfig(n.mCM, "")
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return ErrTLSCertNotDefined
+	}
+
+	opts.TLS = true
+	opts.TLSConfig = config
+	opts.TLSVerify = tlsOpts.Verify
+	opts.TLSMap = tlsOpts.Map
+	opts.TLSPinnedCerts = tlsOpts.PinnedCerts
+	return nil
+}
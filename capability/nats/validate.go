@@ -0,0 +1,96 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+var ErrInvalidPort = errors.New("invalid port")
+var ErrAuthConflict = errors.New("auth: username and authorization can not be combined")
+var ErrStoreDirNotDefined = errors.New("jetstream: store_dir not defined")
+var ErrLameDuckNotSupported = errors.New("lame duck: lame_duck_duration and lame_duck_grace_period are not supported, Stop shuts the server down right away")
+
+// ConfigError is returned by Setup and Validate when the config map does
+// not describe a valid server, Err holds the cause
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return "nats: invalid configuration: " + e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// checkOptions catches the mistakes the server would accept silently or
+// only report once it is started
+func checkOptions(opts *server.Options) error {
+	var ports = []struct {
+		key  string
+		port int
+	}{
+		{"port", opts.Port},
+		{"http_port", opts.HTTPPort},
+		{"https_port", opts.HTTPSPort},
+		{"prof_port", opts.ProfPort},
+	}
+	for _, p := range ports {
+		if p.port < -1 || p.port > 65535 {
+			return fmt.Errorf("%s: %w %d", p.key, ErrInvalidPort, p.port)
+		}
+	}
+
+	if len(opts.Username) != 0 && len(opts.Authorization) != 0 {
+		return ErrAuthConflict
+	}
+
+	if opts.JetStream && len(opts.StoreDir) == 0 {
+		return ErrStoreDirNotDefined
+	}
+
+	// nats-server 2.8.4 only enters lame duck mode from its SIGUSR2 handler
+	if opts.LameDuckDuration != 0 || opts.LameDuckGracePeriod != 0 {
+		return ErrLameDuckNotSupported
+	}
+
+	return nil
+}
+
+// Validate assembles the server options and checks them the way
+// check_config does, the server is created but never started and n is
+// left unchanged
+func (n *Nats) Validate() error {
+	var resolver server.AccountResolver
+	if n.mResolverOptions != nil {
+		// the directory resolvers create their directory and shutting
+		// the throwaway server down closes its resolver, a memory
+		// resolver holding the same accounts stands in for it
+		var memory = *n.mResolverOptions
+		memory.kind = ResolverMemory
+
+		var err error
+		if resolver, err = memory.accountResolver(); err != nil {
+			return &ConfigError{Err: err}
+		}
+	}
+
+	opts, err := n.options(resolver)
+	if err != nil {
+		return err
+	}
+
+	// the throwaway server must not install the signal handlers
+	opts.NoSigs = true
+
+	srv, err := server.NewServer(opts)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	srv.Shutdown()
+	return nil
+}
@@ -0,0 +1,60 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/mkawserm/abesh/model"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+var ErrWebsocketTLSNotDefined = errors.New("websocket: websocket_tls_cert and websocket_tls_key not defined, set websocket_no_tls to listen without tls")
+
+// websocketOptions reads the `websocket_*` keys, the listener requires tls
+// unless websocket_no_tls is set explicitly
+func websocketOptions(cm model.ConfigMap) (server.WebsocketOpts, error) {
+	var websocket = server.WebsocketOpts{
+		Host:             cm.String("websocket_host", ""),
+		Advertise:        cm.String("websocket_advertise", ""),
+		NoAuthUser:       cm.String("websocket_no_auth_user", ""),
+		JWTCookie:        cm.String("websocket_jwt_cookie", ""),
+		Username:         cm.String("websocket_username", ""),
+		Password:         cm.String("websocket_password", ""),
+		Token:            cm.String("websocket_token", ""),
+		AuthTimeout:      cm.Float64("websocket_auth_timeout", 0),
+		NoTLS:            cm.Bool("websocket_no_tls", false),
+		SameOrigin:       cm.Bool("websocket_same_origin", false),
+		AllowedOrigins:   stringList(cm, "websocket_allowed_origins"),
+		Compression:      cm.Bool("websocket_compression", false),
+		HandshakeTimeout: cm.Duration("websocket_handshake_timeout", 0),
+	}
+
+	var err error
+	if websocket.Port, err = port(cm, "websocket_port"); err != nil {
+		return server.WebsocketOpts{}, err
+	}
+
+	for _, origin := range websocket.AllowedOrigins {
+		if _, err = url.Parse(origin); err != nil {
+			return server.WebsocketOpts{}, fmt.Errorf("websocket_allowed_origins: invalid origin %q: %v", origin, err)
+		}
+	}
+
+	config, opts, err := tlsConfig(cm, "websocket_")
+	if err != nil {
+		return server.WebsocketOpts{}, err
+	}
+	if config != nil {
+		websocket.TLSConfig = config
+		websocket.TLSMap = opts.Map
+		websocket.TLSPinnedCerts = opts.PinnedCerts
+	}
+
+	// the listener is disabled without a port
+	if websocket.Port != 0 && websocket.TLSConfig == nil && !websocket.NoTLS {
+		return server.WebsocketOpts{}, ErrWebsocketTLSNotDefined
+	}
+
+	return websocket, nil
+}
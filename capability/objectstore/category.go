@@ -0,0 +1,7 @@
+package objectstore
+
+import (
+	"github.com/mkawserm/abesh/constant"
+)
+
+const Category = string(constant.CategoryStorage)
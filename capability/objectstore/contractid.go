@@ -0,0 +1,3 @@
+package objectstore
+
+const ContractId = "abesh:nats:objectstore"
@@ -0,0 +1,3 @@
+package objectstore
+
+const Name = "abesh_nats_objectstore"
@@ -0,0 +1,202 @@
+package objectstore
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mkawserm/abesh/iface"
+	"github.com/mkawserm/abesh/model"
+	"github.com/mkawserm/abesh/registry"
+	"github.com/nats-io/nats.go"
+
+	"github.com/amjadjibon/nats/capability/conn"
+	"github.com/amjadjibon/nats/constant"
+)
+
+type ObjectStore struct {
+	mCM                      model.ConfigMap
+	mMu                      sync.Mutex
+	mConn                    *nats.Conn
+	mObjectStore             nats.ObjectStore
+	mObjectBucket            string
+	mObjectBucketDescription string
+	mObjectBucketTTL         time.Duration
+	mObjectBucketMaxBytes    int64
+	mObjectBucketStorage     int
+	mObjectBucketReplicas    int
+	mConnContractId          string
+	mConnOptions             *conn.Options
+	mCapabilityRegistry      iface.ICapabilityRegistry
+}
+
+func (o *ObjectStore) Name() string {
+	return Name
+}
+
+func (o *ObjectStore) Version() string {
+	return constant.NatsVersion
+}
+
+func (o *ObjectStore) Category() string {
+	return Category
+}
+
+func (o *ObjectStore) ContractId() string {
+	return ContractId
+}
+
+func (o *ObjectStore) New() iface.ICapability {
+	return &ObjectStore{}
+}
+
+func (o *ObjectStore) SetConfigMap(cm model.ConfigMap) error {
+	o.mCM = cm
+	o.mObjectBucket = cm.String("object_bucket", "objectstore")
+	o.mObjectBucketDescription = cm.String("object_bucket_description", "nats objectstore")
+	o.mObjectBucketTTL = cm.Duration("object_bucket_ttl", 0)
+	o.mObjectBucketMaxBytes = cm.Int64("object_bucket_max_bytes", 0)
+	o.mObjectBucketStorage = cm.Int("object_bucket_storage", 0)
+	o.mObjectBucketReplicas = cm.Int("object_bucket_replicas", 0)
+	o.mConnContractId = cm.String("conn", "")
+	o.mConnOptions = conn.NewOptions(cm, "abesh_nats_objectstore", false)
+	return nil
+}
+
+func (o *ObjectStore) GetConfigMap() model.ConfigMap {
+	return o.mCM
+}
+
+func (o *ObjectStore) SetCapabilityRegistry(capabilityRegistry iface.ICapabilityRegistry) error {
+	o.mCapabilityRegistry = capabilityRegistry
+	return nil
+}
+
+func (o *ObjectStore) GetCapabilityRegistry() iface.ICapabilityRegistry {
+	return o.mCapabilityRegistry
+}
+
+// Stop drains the connection unless it is shared through a conn capability
+func (o *ObjectStore) Stop(ctx context.Context) error {
+	o.mMu.Lock()
+	defer o.mMu.Unlock()
+
+	o.mObjectStore = nil
+
+	if o.mConn != nil {
+		var err = o.mConn.Drain()
+		o.mConn = nil
+		return err
+	}
+
+	return nil
+}
+
+func (o *ObjectStore) objectStore(connect *nats.Conn) (nats.ObjectStore, error) {
+	stream, err := connect.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	obs, err := stream.ObjectStore(o.mObjectBucket)
+	if err == nil {
+		return obs, nil
+	}
+	// the object store is backed by a stream, a missing bucket
+	// is reported as a missing stream
+	if err == nats.ErrStreamNotFound || err == nats.ErrBucketNotFound {
+		obs, err = stream.CreateObjectStore(&nats.ObjectStoreConfig{
+			Bucket:      o.mObjectBucket,
+			Description: o.mObjectBucketDescription,
+			TTL:         o.mObjectBucketTTL,
+			MaxBytes:    o.mObjectBucketMaxBytes,
+			Storage:     nats.StorageType(o.mObjectBucketStorage),
+			Replicas:    o.mObjectBucketReplicas,
+		})
+		if err == nil {
+			return obs, nil
+		}
+	}
+	return nil, err
+}
+
+func (o *ObjectStore) SetObjectStore() error {
+	o.mMu.Lock()
+	defer o.mMu.Unlock()
+
+	if o.mObjectStore != nil {
+		return nil
+	}
+
+	nc, owned, err := conn.Connect(o.mCapabilityRegistry, o.mConnContractId, o.mConnOptions)
+	if err != nil {
+		return err
+	}
+
+	obs, err := o.objectStore(nc)
+	if err != nil {
+		if owned {
+			nc.Close()
+		}
+		return err
+	}
+
+	if owned {
+		o.mConn = nc
+	}
+	o.mObjectStore = obs
+
+	return nil
+}
+
+// Put streams the reader into the named object, replacing any previous object
+func (o *ObjectStore) Put(ctx context.Context, name string, reader io.Reader) (*nats.ObjectInfo, error) {
+	if err := o.SetObjectStore(); err != nil {
+		return nil, err
+	}
+
+	return o.mObjectStore.Put(&nats.ObjectMeta{Name: name}, reader, nats.Context(ctx))
+}
+
+// Get returns a reader for the named object, the caller must close it
+func (o *ObjectStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := o.SetObjectStore(); err != nil {
+		return nil, err
+	}
+
+	return o.mObjectStore.Get(name, nats.Context(ctx))
+}
+
+func (o *ObjectStore) Delete(ctx context.Context, name string) error {
+	if err := o.SetObjectStore(); err != nil {
+		return err
+	}
+
+	return o.mObjectStore.Delete(name)
+}
+
+// List returns the info of every object in the bucket, deleted objects are skipped
+func (o *ObjectStore) List(ctx context.Context) ([]*nats.ObjectInfo, error) {
+	if err := o.SetObjectStore(); err != nil {
+		return nil, err
+	}
+
+	infos, err := o.mObjectStore.List(nats.Context(ctx))
+	if err == nats.ErrNoObjectsFound {
+		return []*nats.ObjectInfo{}, nil
+	}
+
+	return infos, err
+}
+
+func (o *ObjectStore) GetInfo(ctx context.Context, name string) (*nats.ObjectInfo, error) {
+	if err := o.SetObjectStore(); err != nil {
+		return nil, err
+	}
+
+	return o.mObjectStore.GetInfo(name)
+}
+
+func init() {
+	registry.GlobalRegistry().AddCapability(&ObjectStore{})
+}
@@ -0,0 +1,3 @@
+package publisher
+
+const ContractId = "abesh:nats:publisher"
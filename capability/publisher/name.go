@@ -0,0 +1,3 @@
+package publisher
+
+const Name = "abesh_nats_publisher"
@@ -0,0 +1,245 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	encodingIface "github.com/amjadjibon/encoding/iface"
+	encodingRegistry "github.com/amjadjibon/encoding/registry"
+	"github.com/mkawserm/abesh/iface"
+	"github.com/mkawserm/abesh/model"
+	"github.com/mkawserm/abesh/registry"
+	"github.com/nats-io/nats.go"
+
+	"github.com/amjadjibon/nats/capability/conn"
+	"github.com/amjadjibon/nats/constant"
+)
+
+var ErrEncodingNotFound = errors.New("encoding not found")
+var ErrPublishAsyncPending = errors.New("publisher: async publishes still pending after drain_timeout")
+
+type Publisher struct {
+	mCM                  model.ConfigMap
+	mMu                  sync.Mutex
+	mConn                *nats.Conn
+	mJS                  nats.JetStreamContext
+	mEncoding            encodingIface.IEncoding
+	mEncodingName        string
+	mOwnConn             bool
+	mPublishAsyncMaxPend int
+	mContentType         string
+	mConnContractId      string
+	mConnOptions         *conn.Options
+	mCapabilityRegistry  iface.ICapabilityRegistry
+}
+
+func (p *Publisher) Name() string {
+	return Name
+}
+
+func (p *Publisher) Version() string {
+	return constant.NatsVersion
+}
+
+func (p *Publisher) Category() string {
+	return Category
+}
+
+func (p *Publisher) ContractId() string {
+	return ContractId
+}
+
+func (p *Publisher) New() iface.ICapability {
+	return &Publisher{}
+}
+
+func (p *Publisher) SetConfigMap(cm model.ConfigMap) error {
+	p.mCM = cm
+	p.mEncodingName = cm.String("encoding", "json")
+	p.mContentType = cm.String("content_type", "application/"+p.mEncodingName)
+	p.mPublishAsyncMaxPend = cm.Int("publish_async_max_pending", 4000)
+	p.mConnContractId = cm.String("conn", "")
+	p.mConnOptions = conn.NewOptions(cm, "abesh_nats_publisher", false)
+	return nil
+}
+
+func (p *Publisher) GetConfigMap() model.ConfigMap {
+	return p.mCM
+}
+
+func (p *Publisher) SetCapabilityRegistry(capabilityRegistry iface.ICapabilityRegistry) error {
+	p.mCapabilityRegistry = capabilityRegistry
+	return nil
+}
+
+func (p *Publisher) GetCapabilityRegistry() iface.ICapabilityRegistry {
+	return p.mCapabilityRegistry
+}
+
+func (p *Publisher) getJetStream() (*nats.Conn, bool, nats.JetStreamContext, error) {
+	connect, owned, err := conn.Connect(p.mCapabilityRegistry, p.mConnContractId, p.mConnOptions)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	stream, err := connect.JetStream(nats.PublishAsyncMaxPending(p.mPublishAsyncMaxPend))
+	if err != nil {
+		if owned {
+			connect.Close()
+		}
+		return nil, false, nil, err
+	}
+	return connect, owned, stream, nil
+}
+
+func (p *Publisher) SetJetStream() error {
+	_, _, err := p.jetStream()
+	return err
+}
+
+// jetStream connects on first use and returns the handles read under the
+// lock, Stop clears them concurrently
+func (p *Publisher) jetStream() (*nats.Conn, nats.JetStreamContext, error) {
+	p.mMu.Lock()
+	defer p.mMu.Unlock()
+
+	if p.mJS != nil {
+		return p.mConn, p.mJS, nil
+	}
+
+	nc, owned, js, err := p.getJetStream()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mConn = nc
+	p.mOwnConn = owned
+	p.mJS = js
+
+	return nc, js, nil
+}
+
+func (p *Publisher) Setup() error {
+	p.mEncoding = encodingRegistry.EncodingRegistry().GetEncoding(p.mEncodingName)
+	if p.mEncoding == nil {
+		return ErrEncodingNotFound
+	}
+	return nil
+}
+
+// Stop waits for the pending async publishes and drains the connection
+// unless it is shared through a conn capability, pending acks never time
+// out on their own so the wait is bounded by drain_timeout as well as ctx
+func (p *Publisher) Stop(ctx context.Context) error {
+	p.mMu.Lock()
+	var nc, js, owned = p.mConn, p.mJS, p.mOwnConn
+	p.mConn = nil
+	p.mJS = nil
+	p.mMu.Unlock()
+
+	if nc == nil {
+		return nil
+	}
+
+	var timer = time.NewTimer(p.mConnOptions.DrainTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-js.PublishAsyncComplete():
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+		err = ErrPublishAsyncPending
+	}
+
+	if !owned {
+		return err
+	}
+
+	// a connection which did not get its acks back is not drained
+	if err != nil {
+		nc.Close()
+		return err
+	}
+
+	return nc.Drain()
+}
+
+func (p *Publisher) newMsg(subject string, msgId string, value interface{}) (*nats.Msg, error) {
+	data, err := p.mEncoding.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg = nats.NewMsg(subject)
+	msg.Data = data
+	msg.Header.Set("Content-Type", p.mContentType)
+	if len(msgId) != 0 {
+		msg.Header.Set(nats.MsgIdHdr, msgId)
+	}
+
+	return msg, nil
+}
+
+// Publish encodes the value and publishes it to JetStream, a non-empty
+// msgId is sent as Nats-Msg-Id so the stream can drop duplicates
+func (p *Publisher) Publish(ctx context.Context, subject string, msgId string, value interface{}) (*nats.PubAck, error) {
+	_, js, err := p.jetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := p.newMsg(subject, msgId, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return js.PublishMsg(msg, nats.Context(ctx))
+}
+
+// PublishAsync is the non-blocking variant of Publish, the returned future
+// resolves to the PubAck or the publish error
+func (p *Publisher) PublishAsync(ctx context.Context, subject string, msgId string, value interface{}) (nats.PubAckFuture, error) {
+	_, js, err := p.jetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := p.newMsg(subject, msgId, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return js.PublishMsgAsync(msg)
+}
+
+// Request sends the encoded value over core NATS and decodes the reply
+// into response, a nil response discards the reply body
+func (p *Publisher) Request(ctx context.Context, subject string, value interface{}, response interface{}) error {
+	nc, _, err := p.jetStream()
+	if err != nil {
+		return err
+	}
+
+	msg, err := p.newMsg(subject, "", value)
+	if err != nil {
+		return err
+	}
+
+	reply, err := nc.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	return p.mEncoding.Unmarshal(reply.Data, response)
+}
+
+func init() {
+	registry.GlobalRegistry().AddCapability(&Publisher{})
+}
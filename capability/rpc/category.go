@@ -0,0 +1,10 @@
+package rpc
+
+import (
+	"github.com/mkawserm/abesh/constant"
+)
+
+// Category is trigger because the abesh platform only binds services
+// to trigger capabilities, endpoints are listed under `triggers:` and
+// authorized by the authorizer of their trigger binding
+const Category = string(constant.CategoryTrigger)
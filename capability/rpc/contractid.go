@@ -0,0 +1,3 @@
+package rpc
+
+const ContractId = "abesh:nats:rpc"
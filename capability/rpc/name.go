@@ -0,0 +1,3 @@
+package rpc
+
+const Name = "abesh_nats_rpc"
@@ -0,0 +1,368 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkawserm/abesh/iface"
+	"github.com/mkawserm/abesh/logger"
+	"github.com/mkawserm/abesh/model"
+	"github.com/mkawserm/abesh/registry"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/amjadjibon/nats/capability/conn"
+	"github.com/amjadjibon/nats/constant"
+)
+
+const (
+	StatusCodeHeader  = "Abesh-Status-Code"
+	StatusHeader      = "Abesh-Status"
+	ContentTypeHeader = "Content-Type"
+)
+
+var _ iface.ITrigger = (*RPC)(nil)
+
+type endpoint struct {
+	subject              string
+	queue                string
+	authorizer           iface.IAuthorizer
+	authorizerExpression string
+	service              iface.IService
+}
+
+type RPC struct {
+	mCM                 model.ConfigMap
+	mConn               *nats.Conn
+	mOwnConn            bool
+	mSubscriptions      []*nats.Subscription
+	mEndpoints          []*endpoint
+	mEventTransmitter   iface.IEventTransmitter
+	mCapabilityRegistry iface.ICapabilityRegistry
+
+	mSubjectPrefix      string
+	mQueueGroup         string
+	mConnContractId     string
+	mConnOptions        *conn.Options
+	mDefaultContentType string
+	mRequestTimeout     time.Duration
+}
+
+func (r *RPC) Name() string {
+	return Name
+}
+
+func (r *RPC) Version() string {
+	return constant.NatsVersion
+}
+
+func (r *RPC) Category() string {
+	return Category
+}
+
+func (r *RPC) ContractId() string {
+	return ContractId
+}
+
+func (r *RPC) New() iface.ICapability {
+	return &RPC{}
+}
+
+func (r *RPC) GetConfigMap() model.ConfigMap {
+	return r.mCM
+}
+
+func (r *RPC) SetConfigMap(cm model.ConfigMap) error {
+	r.mCM = cm
+	r.mConnContractId = cm.String("conn", "")
+	r.mConnOptions = conn.NewOptions(cm, "abesh_nats_rpc", true)
+	r.mSubjectPrefix = cm.String("subject_prefix", "abesh.rpc")
+	r.mQueueGroup = cm.String("queue_group", "abesh_nats_rpc")
+	r.mDefaultContentType = cm.String("default_content_type", "application/octet-stream")
+	r.mRequestTimeout = cm.Duration("default_request_timeout", time.Second)
+	return nil
+}
+
+func (r *RPC) Setup() error {
+	r.mEndpoints = make([]*endpoint, 0)
+	r.mSubscriptions = make([]*nats.Subscription, 0)
+	return nil
+}
+
+func (r *RPC) SetCapabilityRegistry(capabilityRegistry iface.ICapabilityRegistry) error {
+	r.mCapabilityRegistry = capabilityRegistry
+	return nil
+}
+
+func (r *RPC) GetCapabilityRegistry() iface.ICapabilityRegistry {
+	return r.mCapabilityRegistry
+}
+
+func (r *RPC) connect() (*nats.Conn, error) {
+	nc, owned, err := conn.Connect(r.mCapabilityRegistry, r.mConnContractId, r.mConnOptions)
+	if err != nil {
+		return nil, err
+	}
+	r.mOwnConn = owned
+	return nc, nil
+}
+
+func (r *RPC) Start(ctx context.Context) error {
+	nc, err := r.connect()
+	if err != nil {
+		return err
+	}
+	r.mConn = nc
+
+	for _, e := range r.mEndpoints {
+		var sub *nats.Subscription
+		if len(e.queue) != 0 {
+			sub, err = nc.QueueSubscribe(e.subject, e.queue, r.handler(e))
+		} else {
+			sub, err = nc.Subscribe(e.subject, r.handler(e))
+		}
+		if err != nil {
+			return err
+		}
+
+		r.mSubscriptions = append(r.mSubscriptions, sub)
+
+		logger.L(r.ContractId()).Info("rpc endpoint registered",
+			zap.String("subject", e.subject),
+			zap.String("queue", e.queue),
+			zap.String("service", e.service.ContractId()))
+	}
+
+	return nil
+}
+
+func (r *RPC) Stop(ctx context.Context) error {
+	if r.mConn == nil {
+		return nil
+	}
+
+	if r.mOwnConn {
+		return r.mConn.Drain()
+	}
+
+	for _, sub := range r.mSubscriptions {
+		if err := sub.Drain(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *RPC) SetEventTransmitter(eventTransmitter iface.IEventTransmitter) error {
+	r.mEventTransmitter = eventTransmitter
+	return nil
+}
+
+func (r *RPC) GetEventTransmitter() iface.IEventTransmitter {
+	return r.mEventTransmitter
+}
+
+func (r *RPC) TransmitInputEvent(contractId string, event *model.Event) error {
+	if r.GetEventTransmitter() != nil {
+		go func() {
+			var err = r.GetEventTransmitter().TransmitInputEvent(contractId, event)
+			if err != nil {
+				logger.L(r.ContractId()).Error(err.Error(),
+					zap.String("version", r.Version()),
+					zap.String("name", r.Name()),
+					zap.String("contract_id", r.ContractId()))
+			}
+		}()
+	}
+	return nil
+}
+
+func (r *RPC) TransmitOutputEvent(contractId string, event *model.Event) error {
+	if r.GetEventTransmitter() != nil {
+		go func() {
+			err := r.GetEventTransmitter().TransmitOutputEvent(contractId, event)
+			if err != nil {
+				logger.L(r.ContractId()).Error(err.Error(),
+					zap.String("version", r.Version()),
+					zap.String("name", r.Name()),
+					zap.String("contract_id", r.ContractId()))
+			}
+		}()
+	}
+	return nil
+}
+
+// AddService maps the service to a subject, the subject is the trigger value
+// `subject` or the service contract id when absent, prefixed by `subject_prefix`
+func (r *RPC) AddService(
+	authorizer iface.IAuthorizer,
+	authorizerExpression string,
+	triggerValues model.ConfigMap,
+	service iface.IService) error {
+
+	logger.L(r.ContractId()).Debug("service add",
+		zap.Any("authorizer", authorizer),
+		zap.Any("expression", authorizerExpression),
+		zap.Any("triggerValues", triggerValues))
+
+	var subject = strings.TrimSpace(triggerValues.String("subject", ""))
+	if len(subject) == 0 {
+		subject = strings.ReplaceAll(service.ContractId(), ":", ".")
+	}
+
+	if len(r.mSubjectPrefix) != 0 {
+		subject = r.mSubjectPrefix + "." + subject
+	}
+
+	r.mEndpoints = append(r.mEndpoints, &endpoint{
+		subject:              subject,
+		queue:                strings.TrimSpace(triggerValues.String("queue", r.mQueueGroup)),
+		authorizer:           authorizer,
+		authorizerExpression: authorizerExpression,
+		service:              service,
+	})
+
+	return nil
+}
+
+func (r *RPC) authorize(e *endpoint, metadata *model.Metadata) bool {
+	if e.authorizer != nil {
+		return e.authorizer.IsAuthorized(e.authorizerExpression, metadata)
+	}
+
+	return true
+}
+
+func (r *RPC) handler(e *endpoint) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		timerStart := time.Now()
+
+		defer func() {
+			elapsed := time.Since(timerStart)
+			logger.L(r.ContractId()).Debug("request execution time",
+				zap.String("subject", msg.Subject),
+				zap.Duration("seconds", elapsed))
+		}()
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				logger.L(r.ContractId()).Error("panic data",
+					zap.String("subject", msg.Subject),
+					zap.String("reply", msg.Reply),
+					zap.String("panic_msg", fmt.Sprintf("%v", rec)))
+				r.respondStatus(msg, 500, "INTERNAL SERVER ERROR")
+			}
+		}()
+
+		var inputEvent = r.msgToEvent(msg)
+
+		if !r.authorize(e, inputEvent.Metadata) {
+			r.respondStatus(msg, 403, "FORBIDDEN")
+			return
+		}
+
+		_ = r.TransmitInputEvent(e.service.ContractId(), inputEvent)
+
+		nCtx, cancel := context.WithTimeout(context.Background(), r.mRequestTimeout)
+		defer cancel()
+
+		outputEvent, err := e.service.Serve(nCtx, inputEvent)
+		if errors.Is(err, context.DeadlineExceeded) {
+			r.respondStatus(msg, 408, "REQUEST TIMEOUT")
+			return
+		}
+
+		if errors.Is(err, context.Canceled) {
+			r.respondStatus(msg, 499, "REQUEST CANCELLED")
+			return
+		}
+
+		if err != nil {
+			logger.L(r.ContractId()).Error(err.Error(),
+				zap.String("subject", msg.Subject),
+				zap.String("service", e.service.ContractId()))
+			r.respondStatus(msg, 500, "INTERNAL SERVER ERROR")
+			return
+		}
+
+		if outputEvent == nil {
+			r.respondStatus(msg, 204, "NO CONTENT")
+			return
+		}
+
+		_ = r.TransmitOutputEvent(e.service.ContractId(), outputEvent)
+
+		r.respond(msg, outputEvent)
+	}
+}
+
+func (r *RPC) msgToEvent(msg *nats.Msg) *model.Event {
+	var metadata = &model.Metadata{}
+	metadata.Method = msg.Subject
+	metadata.Headers = make(map[string]string)
+	metadata.SubscriptionSubject = msg.Subject
+	metadata.ReplySubject = msg.Reply
+	metadata.ContractIdList = append(metadata.ContractIdList, r.ContractId())
+
+	var typeUrl = r.mDefaultContentType
+	for k, v := range msg.Header {
+		if len(v) > 0 {
+			metadata.Headers[k] = v[0]
+			if strings.ToLower(k) == "content-type" {
+				typeUrl = v[0]
+			}
+		}
+	}
+
+	return &model.Event{
+		Metadata: metadata,
+		TypeUrl:  typeUrl,
+		Value:    msg.Data,
+	}
+}
+
+func (r *RPC) respond(msg *nats.Msg, event *model.Event) {
+	if len(msg.Reply) == 0 {
+		return
+	}
+
+	var reply = nats.NewMsg(msg.Reply)
+	reply.Data = event.Value
+
+	if event.Metadata != nil {
+		for k, v := range event.Metadata.Headers {
+			reply.Header.Set(k, v)
+		}
+		reply.Header.Set(StatusCodeHeader, strconv.FormatUint(uint64(event.Metadata.StatusCode), 10))
+		reply.Header.Set(StatusHeader, event.Metadata.Status)
+	}
+
+	if len(event.TypeUrl) != 0 {
+		reply.Header.Set(ContentTypeHeader, event.TypeUrl)
+	}
+
+	if err := msg.RespondMsg(reply); err != nil {
+		logger.L(r.ContractId()).Error(err.Error(),
+			zap.String("version", r.Version()),
+			zap.String("name", r.Name()),
+			zap.String("contract_id", r.ContractId()))
+	}
+}
+
+func (r *RPC) respondStatus(msg *nats.Msg, statusCode uint32, status string) {
+	r.respond(msg, &model.Event{
+		Metadata: &model.Metadata{
+			StatusCode: statusCode,
+			Status:     status,
+		},
+	})
+}
+
+func init() {
+	registry.GlobalRegistry().AddCapability(&RPC{})
+}
@@ -0,0 +1,7 @@
+package trigger
+
+import (
+	"github.com/mkawserm/abesh/constant"
+)
+
+const Category = string(constant.CategoryTrigger)
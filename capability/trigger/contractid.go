@@ -0,0 +1,3 @@
+package trigger
+
+const ContractId = "abesh:nats:trigger"
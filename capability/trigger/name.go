@@ -0,0 +1,3 @@
+package trigger
+
+const Name = "abesh_nats_trigger"
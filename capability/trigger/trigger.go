@@ -0,0 +1,301 @@
+package trigger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mkawserm/abesh/iface"
+	"github.com/mkawserm/abesh/logger"
+	"github.com/mkawserm/abesh/model"
+	"github.com/mkawserm/abesh/registry"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/amjadjibon/nats/capability/conn"
+	"github.com/amjadjibon/nats/constant"
+)
+
+var ErrSubjectNotDefined = errors.New("subject not defined")
+
+type subscriber struct {
+	subject              string
+	queue                string
+	authorizer           iface.IAuthorizer
+	authorizerExpression string
+	service              iface.IService
+}
+
+type Trigger struct {
+	mCM                 model.ConfigMap
+	mConn               *nats.Conn
+	mOwnConn            bool
+	mSubscriptions      []*nats.Subscription
+	mSubscribers        []*subscriber
+	mEventTransmitter   iface.IEventTransmitter
+	mCapabilityRegistry iface.ICapabilityRegistry
+
+	mConnContractId     string
+	mConnOptions        *conn.Options
+	mDefaultContentType string
+	mDefaultQueue       string
+	mRequestTimeout     time.Duration
+	mPendingMsgsLimit   int
+	mPendingBytesLimit  int
+}
+
+func (t *Trigger) Name() string {
+	return Name
+}
+
+func (t *Trigger) Version() string {
+	return constant.NatsVersion
+}
+
+func (t *Trigger) Category() string {
+	return Category
+}
+
+func (t *Trigger) ContractId() string {
+	return ContractId
+}
+
+func (t *Trigger) New() iface.ICapability {
+	return &Trigger{}
+}
+
+func (t *Trigger) GetConfigMap() model.ConfigMap {
+	return t.mCM
+}
+
+func (t *Trigger) SetConfigMap(cm model.ConfigMap) error {
+	t.mCM = cm
+	t.mConnContractId = cm.String("conn", "")
+	t.mConnOptions = conn.NewOptions(cm, "abesh_nats_trigger", true)
+	t.mDefaultContentType = cm.String("default_content_type", "application/octet-stream")
+	t.mDefaultQueue = cm.String("default_queue", "")
+	t.mRequestTimeout = cm.Duration("default_request_timeout", time.Second)
+	t.mPendingMsgsLimit = cm.Int("pending_msgs_limit", nats.DefaultSubPendingMsgsLimit)
+	t.mPendingBytesLimit = cm.Int("pending_bytes_limit", nats.DefaultSubPendingBytesLimit)
+	return nil
+}
+
+func (t *Trigger) Setup() error {
+	t.mSubscribers = make([]*subscriber, 0)
+	t.mSubscriptions = make([]*nats.Subscription, 0)
+	return nil
+}
+
+func (t *Trigger) SetCapabilityRegistry(capabilityRegistry iface.ICapabilityRegistry) error {
+	t.mCapabilityRegistry = capabilityRegistry
+	return nil
+}
+
+func (t *Trigger) GetCapabilityRegistry() iface.ICapabilityRegistry {
+	return t.mCapabilityRegistry
+}
+
+func (t *Trigger) connect() (*nats.Conn, error) {
+	nc, owned, err := conn.Connect(t.mCapabilityRegistry, t.mConnContractId, t.mConnOptions)
+	if err != nil {
+		return nil, err
+	}
+	t.mOwnConn = owned
+	return nc, nil
+}
+
+func (t *Trigger) Start(ctx context.Context) error {
+	nc, err := t.connect()
+	if err != nil {
+		return err
+	}
+	t.mConn = nc
+
+	for _, s := range t.mSubscribers {
+		var sub *nats.Subscription
+		if len(s.queue) != 0 {
+			sub, err = nc.QueueSubscribe(s.subject, s.queue, t.handler(s))
+		} else {
+			sub, err = nc.Subscribe(s.subject, t.handler(s))
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = sub.SetPendingLimits(t.mPendingMsgsLimit, t.mPendingBytesLimit); err != nil {
+			return err
+		}
+
+		t.mSubscriptions = append(t.mSubscriptions, sub)
+
+		logger.L(t.ContractId()).Info("subscribed",
+			zap.String("subject", s.subject),
+			zap.String("queue", s.queue),
+			zap.String("service", s.service.ContractId()))
+	}
+
+	return nil
+}
+
+func (t *Trigger) Stop(ctx context.Context) error {
+	if t.mConn == nil {
+		return nil
+	}
+
+	if t.mOwnConn {
+		return t.mConn.Drain()
+	}
+
+	for _, sub := range t.mSubscriptions {
+		if err := sub.Drain(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *Trigger) SetEventTransmitter(eventTransmitter iface.IEventTransmitter) error {
+	t.mEventTransmitter = eventTransmitter
+	return nil
+}
+
+func (t *Trigger) GetEventTransmitter() iface.IEventTransmitter {
+	return t.mEventTransmitter
+}
+
+func (t *Trigger) TransmitInputEvent(contractId string, event *model.Event) error {
+	if t.GetEventTransmitter() != nil {
+		go func() {
+			var err = t.GetEventTransmitter().TransmitInputEvent(contractId, event)
+			if err != nil {
+				logger.L(t.ContractId()).Error(err.Error(),
+					zap.String("version", t.Version()),
+					zap.String("name", t.Name()),
+					zap.String("contract_id", t.ContractId()))
+			}
+		}()
+	}
+	return nil
+}
+
+func (t *Trigger) TransmitOutputEvent(contractId string, event *model.Event) error {
+	if t.GetEventTransmitter() != nil {
+		go func() {
+			err := t.GetEventTransmitter().TransmitOutputEvent(contractId, event)
+			if err != nil {
+				logger.L(t.ContractId()).Error(err.Error(),
+					zap.String("version", t.Version()),
+					zap.String("name", t.Name()),
+					zap.String("contract_id", t.ContractId()))
+			}
+		}()
+	}
+	return nil
+}
+
+func (t *Trigger) AddService(
+	authorizer iface.IAuthorizer,
+	authorizerExpression string,
+	triggerValues model.ConfigMap,
+	service iface.IService) error {
+
+	logger.L(t.ContractId()).Debug("service add",
+		zap.Any("authorizer", authorizer),
+		zap.Any("expression", authorizerExpression),
+		zap.Any("triggerValues", triggerValues))
+
+	var subject = strings.TrimSpace(triggerValues.String("subject", ""))
+	if len(subject) == 0 {
+		return ErrSubjectNotDefined
+	}
+
+	t.mSubscribers = append(t.mSubscribers, &subscriber{
+		subject:              subject,
+		queue:                strings.TrimSpace(triggerValues.String("queue", t.mDefaultQueue)),
+		authorizer:           authorizer,
+		authorizerExpression: authorizerExpression,
+		service:              service,
+	})
+
+	return nil
+}
+
+func (t *Trigger) handler(s *subscriber) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		timerStart := time.Now()
+
+		defer func() {
+			elapsed := time.Since(timerStart)
+			logger.L(t.ContractId()).Debug("message execution time",
+				zap.String("subject", msg.Subject),
+				zap.Duration("seconds", elapsed))
+		}()
+
+		defer func() {
+			if r := recover(); r != nil {
+				logger.L(t.ContractId()).Error("panic data",
+					zap.String("subject", msg.Subject),
+					zap.String("reply", msg.Reply),
+					zap.String("panic_msg", fmt.Sprintf("%v", r)))
+			}
+		}()
+
+		var inputEvent = t.msgToEvent(msg)
+
+		if s.authorizer != nil {
+			if !s.authorizer.IsAuthorized(s.authorizerExpression, inputEvent.Metadata) {
+				logger.L(t.ContractId()).Debug("message not authorized",
+					zap.String("subject", msg.Subject))
+				return
+			}
+		}
+
+		_ = t.TransmitInputEvent(s.service.ContractId(), inputEvent)
+
+		nCtx, cancel := context.WithTimeout(context.Background(), t.mRequestTimeout)
+		defer cancel()
+
+		outputEvent, err := s.service.Serve(nCtx, inputEvent)
+		if err != nil {
+			logger.L(t.ContractId()).Error(err.Error(),
+				zap.String("subject", msg.Subject),
+				zap.String("service", s.service.ContractId()))
+			return
+		}
+
+		if outputEvent != nil {
+			_ = t.TransmitOutputEvent(s.service.ContractId(), outputEvent)
+		}
+	}
+}
+
+func (t *Trigger) msgToEvent(msg *nats.Msg) *model.Event {
+	var metadata = &model.Metadata{}
+	metadata.Headers = make(map[string]string)
+	metadata.SubscriptionSubject = msg.Subject
+	metadata.ReplySubject = msg.Reply
+	metadata.ContractIdList = append(metadata.ContractIdList, t.ContractId())
+
+	var typeUrl = t.mDefaultContentType
+	for k, v := range msg.Header {
+		if len(v) > 0 {
+			metadata.Headers[k] = v[0]
+			if strings.ToLower(k) == "content-type" {
+				typeUrl = v[0]
+			}
+		}
+	}
+
+	return &model.Event{
+		Metadata: metadata,
+		TypeUrl:  typeUrl,
+		Value:    msg.Data,
+	}
+}
+
+func init() {
+	registry.GlobalRegistry().AddCapability(&Trigger{})
+}
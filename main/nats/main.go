@@ -6,9 +6,16 @@ import (
 	_ "github.com/amjadjibon/encoding"
 	"github.com/mkawserm/abesh/cmd"
 
+	_ "github.com/amjadjibon/nats/capability/conn"
+	_ "github.com/amjadjibon/nats/capability/jetstream"
 	_ "github.com/amjadjibon/nats/capability/kv"
+	_ "github.com/amjadjibon/nats/capability/kvwatch"
 	_ "github.com/amjadjibon/nats/capability/metric"
 	_ "github.com/amjadjibon/nats/capability/nats"
+	_ "github.com/amjadjibon/nats/capability/objectstore"
+	_ "github.com/amjadjibon/nats/capability/publisher"
+	_ "github.com/amjadjibon/nats/capability/rpc"
+	_ "github.com/amjadjibon/nats/capability/trigger"
 )
 
 //go:embed manifest.yaml